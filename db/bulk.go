@@ -0,0 +1,309 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+const (
+	// DEFAULT_CHUNK_SIZE 批量插入时每批的默认行数
+	DEFAULT_CHUNK_SIZE = 1000
+	// DEFAULT_MAX_SQL_BYTES 批量插入时每批语句的默认最大字节数，小于mysql默认的max_allowed_packet
+	DEFAULT_MAX_SQL_BYTES = 4 << 20
+)
+
+// BulkInsertOptions 批量插入的选项
+type BulkInsertOptions struct {
+	ChunkSize   int  // 每批的最大行数，为0时使用DEFAULT_CHUNK_SIZE
+	MaxSQLBytes int  // 每批语句的最大字节数，超过时继续拆分，为0时使用DEFAULT_MAX_SQL_BYTES
+	Atomic      bool // 所有批次在同一个事务中执行，任一批失败时全部回滚，否则各批独立执行，失败后继续执行后面的批次
+}
+
+// InsertChunk 批量插入中的一批，Start、End为该批在数据中的行范围[Start, End)
+type InsertChunk struct {
+	Start int
+	End   int
+	SQL   string
+	Args  []interface{}
+	IDs   []interface{} // 该批各行的主键，包含生成的主键
+}
+
+// ChunkResult 批量插入中一批的执行结果
+type ChunkResult struct {
+	Start        int
+	End          int
+	RowsAffected int64
+	IDs          []interface{}
+	Err          error
+}
+
+// BulkInsert 分批插入大量数据，按照行数及语句大小拆分，返回每批的执行结果及第一个错误
+// Atomic为true时，任一批失败即回滚并停止，之前批次的RowsAffected也随之作废；传入事务时在该事务中执行，由调用者提交
+func (engine *Engine) BulkInsert(name string, data []map[string]interface{}, opts BulkInsertOptions, tx ...*sqlx.Tx) ([]ChunkResult, error) {
+	return engine.BulkInsertContext(context.Background(), name, data, opts, tx...)
+}
+
+// BulkInsertContext 同BulkInsert，ctx用于取消及超时
+func (engine *Engine) BulkInsertContext(ctx context.Context, name string, data []map[string]interface{}, opts BulkInsertOptions, tx ...*sqlx.Tx) ([]ChunkResult, error) {
+	chunks, err := engine.BulkInsertSQL(name, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var ownTx *sqlx.Tx
+	if opts.Atomic && (len(tx) == 0 || tx[0] == nil) {
+		if engine.DB == nil {
+			return nil, ErrEngineOffline
+		}
+		if ownTx, err = engine.DB.BeginTxx(ctx, nil); err != nil {
+			return nil, err
+		}
+		tx = []*sqlx.Tx{ownTx}
+	}
+
+	var results []ChunkResult
+	var firstErr error
+	for _, chunk := range chunks {
+		count, err := engine.exec(ctx, chunk.SQL, chunk.Args, tx...)
+		results = append(results, ChunkResult{Start: chunk.Start, End: chunk.End, RowsAffected: count, IDs: chunk.IDs, Err: err})
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("rows [%d, %d): %w", chunk.Start, chunk.End, err)
+		}
+		if err != nil && opts.Atomic {
+			break
+		}
+	}
+
+	if ownTx != nil {
+		if firstErr != nil {
+			_ = ownTx.Rollback()
+		} else if err = ownTx.Commit(); err != nil {
+			return results, err
+		}
+	}
+	return results, firstErr
+}
+
+// BulkInsertSQL 返回BulkInsert各批将要执行的语句及参数，不会访问数据库
+func (engine *Engine) BulkInsertSQL(name string, data []map[string]interface{}, opts BulkInsertOptions) ([]InsertChunk, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize, maxBytes := opts.ChunkSize, opts.MaxSQLBytes
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_CHUNK_SIZE
+	}
+	if maxBytes <= 0 {
+		maxBytes = DEFAULT_MAX_SQL_BYTES
+	}
+
+	rows, err := insertRows(s, data)
+	if err != nil {
+		return nil, err
+	}
+	rows, ids, err := fillIDs(s, rows)
+	if err != nil {
+		return nil, err
+	}
+	rows = normalizeRows(s, rows)
+	build := func(rows []map[string]interface{}) (string, []interface{}, error) {
+		return engine.dialect.BuildInsert(s.TableName, rows)
+	}
+
+	var chunks []InsertChunk
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		sized, err := sizedChunks(rows, start, end, maxBytes, build)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, sized...)
+	}
+	for i := range chunks {
+		chunks[i].IDs = ids[chunks[i].Start:chunks[i].End]
+	}
+	return chunks, nil
+}
+
+// sizedChunks 使用build生成rows[start:end]的语句，语句超过maxBytes时对半拆分，单行超过时不再拆分
+func sizedChunks(rows []map[string]interface{}, start, end, maxBytes int, build func(rows []map[string]interface{}) (string, []interface{}, error)) ([]InsertChunk, error) {
+	sql, args, err := build(rows[start:end])
+	if err != nil {
+		return nil, err
+	}
+	if len(sql) <= maxBytes || end-start == 1 {
+		return []InsertChunk{{Start: start, End: end, SQL: sql, Args: args}}, nil
+	}
+	mid := start + (end-start)/2
+	left, err := sizedChunks(rows, start, mid, maxBytes, build)
+	if err != nil {
+		return nil, err
+	}
+	right, err := sizedChunks(rows, mid, end, maxBytes, build)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// BulkUpdateOptions 批量更新的选项
+type BulkUpdateOptions struct {
+	ChunkSize   int // 每批的最大行数，为0时使用DEFAULT_CHUNK_SIZE
+	MaxSQLBytes int // 每批语句的最大字节数，超过时继续拆分，为0时使用DEFAULT_MAX_SQL_BYTES
+}
+
+// UpdateChunk 批量更新中的一批，IDs为该批更新的主键
+type UpdateChunk = InsertChunk
+
+// BulkUpdate 按主键批量更新各行不同的值，每行必须包含主键，返回影响的总行数
+// 每批生成一条 UPDATE ... SET col = CASE pk WHEN ... END WHERE pk IN (...) 语句，所有批次在同一个事务中执行，任一批失败时全部回滚
+// 传入事务时在该事务中执行，由调用者提交；模型有版本号字段时版本号加1，不校验版本号
+func (engine *Engine) BulkUpdate(name string, data []map[string]interface{}, opts BulkUpdateOptions, tx ...*sqlx.Tx) (int64, error) {
+	return engine.BulkUpdateContext(context.Background(), name, data, opts, tx...)
+}
+
+// BulkUpdateContext 同BulkUpdate，ctx用于取消及超时
+func (engine *Engine) BulkUpdateContext(ctx context.Context, name string, data []map[string]interface{}, opts BulkUpdateOptions, tx ...*sqlx.Tx) (int64, error) {
+	chunks, err := engine.BulkUpdateSQL(name, data, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var ownTx *sqlx.Tx
+	if len(tx) == 0 || tx[0] == nil {
+		if engine.DB == nil {
+			return 0, ErrEngineOffline
+		}
+		if ownTx, err = engine.DB.BeginTxx(ctx, nil); err != nil {
+			return 0, err
+		}
+		tx = []*sqlx.Tx{ownTx}
+	}
+
+	var total int64
+	for _, chunk := range chunks {
+		count, err := engine.exec(ctx, chunk.SQL, chunk.Args, tx...)
+		if err != nil {
+			if ownTx != nil {
+				_ = ownTx.Rollback()
+			}
+			return 0, fmt.Errorf("rows [%d, %d): %w", chunk.Start, chunk.End, err)
+		}
+		total += count
+	}
+	if ownTx != nil {
+		if err = ownTx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// BulkUpdateSQL 返回BulkUpdate各批将要执行的语句及参数，不会访问数据库
+func (engine *Engine) BulkUpdateSQL(name string, data []map[string]interface{}, opts BulkUpdateOptions) ([]UpdateChunk, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	pk := s.PrimaryKey()
+	if pk == nil {
+		return nil, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidField, s.Name)
+	}
+	chunkSize, maxBytes := opts.ChunkSize, opts.MaxSQLBytes
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_CHUNK_SIZE
+	}
+	if maxBytes <= 0 {
+		maxBytes = DEFAULT_MAX_SQL_BYTES
+	}
+
+	rows := make([]map[string]interface{}, len(data))
+	ids := make([]interface{}, len(data))
+	seen := make(map[string]int, len(data))
+	for i, row := range data {
+		if rows[i], _, _, err = updateRow(s, row, nil); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		_, ids[i] = fieldValue(pk, rows[i])
+		// 重复的主键可能被分到不同的批次中，在分批前检查
+		if ids[i] == nil {
+			continue
+		}
+		if j, ok := seen[fmt.Sprint(ids[i])]; ok {
+			return nil, fmt.Errorf("%w: rows %d and %d have the same primary key %v", dialect.ErrInvalidUpdate, j, i, ids[i])
+		}
+		seen[fmt.Sprint(ids[i])] = i
+	}
+	build := func(rows []map[string]interface{}) (string, []interface{}, error) {
+		return engine.dialect.BuildBulkUpdate(s.TableName, pk.Column, rows)
+	}
+
+	var chunks []UpdateChunk
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		sized, err := sizedChunks(rows, start, end, maxBytes, build)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, sized...)
+	}
+	for i := range chunks {
+		chunks[i].IDs = ids[chunks[i].Start:chunks[i].End]
+	}
+	return chunks, nil
+}
+
+// normalizeRows 使所有行具有相同的列，goqu要求插入的各行列相同，缺少的列使用default_raw表达式，没有时为NULL
+// 模型的默认值已在insertRows中补齐
+// 所有行的列都相同时返回原数据
+func normalizeRows(s *schema.Schema, data []map[string]interface{}) []map[string]interface{} {
+	columns := make(map[string]bool)
+	for _, row := range data {
+		for k := range row {
+			columns[util.ToSnake(k)] = true
+		}
+	}
+	uniform := true
+	for _, row := range data {
+		uniform = uniform && len(row) == len(columns)
+	}
+	if uniform {
+		return data
+	}
+
+	rows := make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		normalized := make(map[string]interface{}, len(columns))
+		for k, v := range row {
+			normalized[util.ToSnake(k)] = v
+		}
+		for column := range columns {
+			if _, ok := normalized[column]; !ok {
+				normalized[column] = defaultValue(s.GetFieldByColumn(column))
+			}
+		}
+		rows[i] = normalized
+	}
+	return rows
+}
+
+// defaultValue 字段的数据库默认值，default_raw为数据库表达式，如：CURRENT_TIMESTAMP
+func defaultValue(field *schema.Field) interface{} {
+	if field == nil || !field.IsDefaultRaw {
+		return nil
+	}
+	return goqu.L(field.Default)
+}
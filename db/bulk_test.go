@@ -0,0 +1,104 @@
+package db
+
+import (
+	"fmt"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"testing"
+)
+
+const settingDef = `{
+  "code": "setting",
+  "name": "设置",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "名称", "name": "name", "type": "string"},
+    {"label": "启用", "name": "enabled", "type": "bool", "default": true},
+    {"label": "创建时间", "name": "createdAt", "type": "datetime", "default_raw": "CURRENT_TIMESTAMP"}
+  ]
+}`
+
+func TestEngine_BulkInsertSQL(t *testing.T) {
+	Convey("批量插入分批", t, func() {
+		engine := newTestEngine(settingDef)
+
+		var data []map[string]interface{}
+		for i := 1; i <= 5; i++ {
+			data = append(data, map[string]interface{}{"id": i, "name": fmt.Sprintf("s%d", i)})
+		}
+
+		Convey("按行数拆分", func() {
+			chunks, err := engine.BulkInsertSQL("setting", data, BulkInsertOptions{ChunkSize: 2})
+			So(err, ShouldBeNil)
+			So(len(chunks), ShouldEqual, 3)
+			So(chunks[0].SQL, ShouldEqual, "INSERT INTO `setting` (`enabled`, `id`, `name`) VALUES (1, 1, 's1'), (1, 2, 's2')")
+			So(chunks[2].Start, ShouldEqual, 4)
+			So(chunks[2].End, ShouldEqual, 5)
+			So(chunks[2].SQL, ShouldEqual, "INSERT INTO `setting` (`enabled`, `id`, `name`) VALUES (1, 5, 's5')")
+		})
+
+		Convey("按语句大小拆分", func() {
+			chunks, err := engine.BulkInsertSQL("setting", data, BulkInsertOptions{MaxSQLBytes: 70})
+			So(err, ShouldBeNil)
+			So(len(chunks), ShouldEqual, 5)
+			for i, chunk := range chunks {
+				So(chunk.Start, ShouldEqual, i)
+				So(len(chunk.SQL), ShouldBeLessThanOrEqualTo, 70)
+			}
+		})
+
+		Convey("补齐各行缺少的列", func() {
+			chunks, err := engine.BulkInsertSQL("setting", []map[string]interface{}{
+				{"id": 1, "name": "a"},
+				{"id": 2, "enabled": false, "createdAt": "2023-01-01 00:00:00"},
+			}, BulkInsertOptions{})
+			So(err, ShouldBeNil)
+			So(len(chunks), ShouldEqual, 1)
+			So(chunks[0].SQL, ShouldEqual, "INSERT INTO `setting` (`created_at`, `enabled`, `id`, `name`) "+
+				"VALUES (CURRENT_TIMESTAMP, 1, 1, 'a'), ('2023-01-01 00:00:00', 0, 2, NULL)")
+		})
+
+		Convey("离线引擎不能执行", func() {
+			_, err := engine.BulkInsert("setting", data, BulkInsertOptions{Atomic: true})
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+	})
+}
+
+func TestEngine_BulkUpdateSQL(t *testing.T) {
+	Convey("批量更新分批", t, func() {
+		engine := newTestEngine(settingDef, docDef)
+
+		var data []map[string]interface{}
+		for i := 1; i <= 3; i++ {
+			data = append(data, map[string]interface{}{"id": i, "name": fmt.Sprintf("s%d", i)})
+		}
+
+		Convey("按行数拆分", func() {
+			chunks, err := engine.BulkUpdateSQL("setting", data, BulkUpdateOptions{ChunkSize: 2})
+			So(err, ShouldBeNil)
+			So(len(chunks), ShouldEqual, 2)
+			So(chunks[0].SQL, ShouldEqual, "UPDATE `setting` SET `name`=CASE `id` WHEN 1 THEN 's1' WHEN 2 THEN 's2' ELSE `name` END WHERE (`id` IN (1, 2))")
+			So(chunks[1].IDs, ShouldResemble, []interface{}{int64(3)})
+		})
+
+		Convey("版本号加1", func() {
+			chunks, err := engine.BulkUpdateSQL("doc", []map[string]interface{}{{"id": 1, "title": "a", "lockVersion": 1}}, BulkUpdateOptions{})
+			So(err, ShouldBeNil)
+			So(chunks[0].SQL, ShouldEqual, "UPDATE `doc` SET `lock_version`=CASE `id` WHEN 1 THEN `lock_version` + 1 ELSE `lock_version` END,"+
+				"`title`=CASE `id` WHEN 1 THEN 'a' ELSE `title` END WHERE (`id` IN (1))")
+		})
+
+		Convey("主键重复", func() {
+			// 重复的主键分在不同批次时同样拒绝，json解析的数字与整数视为相同的主键
+			dup := append(data, map[string]interface{}{"id": float64(1), "name": "s4"})
+			_, err := engine.BulkUpdateSQL("setting", dup, BulkUpdateOptions{ChunkSize: 2})
+			So(err, ShouldWrap, dialect.ErrInvalidUpdate)
+		})
+
+		Convey("离线引擎不能执行", func() {
+			_, err := engine.BulkUpdate("setting", data, BulkUpdateOptions{})
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+	})
+}
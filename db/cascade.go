@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// MAX_CASCADE_DEPTH 级联删除的最大层数，避免数据中的循环引用导致无限递归
+const MAX_CASCADE_DEPTH = 16
+
+// hasOnDelete 模型的关系中是否有删除时的处理
+func hasOnDelete(s *schema.Schema) bool {
+	for _, relation := range s.Relations {
+		if relation.OnDelete != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteCascade 按照关系中的onDelete处理关联数据后删除符合条件的数据，返回删除的行数，应在事务中执行
+// 先检查所有restrict关系，再依次处理cascade、setNull及detach，最后按主键删除本模型的数据
+func (engine *Engine) deleteCascade(ctx context.Context, s *schema.Schema, namedCondition map[string]interface{}, depth int, tx *sqlx.Tx) (int64, error) {
+	if depth > MAX_CASCADE_DEPTH {
+		return 0, fmt.Errorf("%w: model %s exceeds %d levels", ErrCascadeTooDeep, s.Name, MAX_CASCADE_DEPTH)
+	}
+	pk := s.PrimaryKey()
+	if pk == nil {
+		return 0, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidField, s.Name)
+	}
+	found, err := engine.FindContext(ctx, s.Name, namedCondition, []string{pk.Name}, tx)
+	if err != nil || len(found) == 0 {
+		return 0, err
+	}
+	ids := make([]interface{}, len(found))
+	for i, row := range found {
+		ids[i] = row[pk.Column]
+	}
+
+	for _, relation := range s.Relations {
+		if relation.OnDelete != schema.ON_DELETE_RESTRICT {
+			continue
+		}
+		table, condition, err := engine.relatedRows(relation, ids)
+		if err != nil {
+			return 0, err
+		}
+		count, err := engine.count(ctx, table, nil, condition, tx)
+		if err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			return 0, fmt.Errorf("%w: %s has %d related rows in relation %s", ErrDeleteRestricted, s.Name, count, relation.Name)
+		}
+	}
+
+	for _, relation := range s.Relations {
+		if err = engine.onDelete(ctx, relation, ids, depth, tx); err != nil {
+			return 0, err
+		}
+	}
+
+	sql, args, err := engine.dialect.BuildDelete(s.TableName, map[string]interface{}{pk.Column + " in": ids})
+	if err != nil {
+		return 0, err
+	}
+	return engine.exec(ctx, sql, args, tx)
+}
+
+// onDelete 处理一个关系的关联数据，ids为将要删除的本模型主键
+func (engine *Engine) onDelete(ctx context.Context, relation *schema.Relation, ids []interface{}, depth int, tx *sqlx.Tx) error {
+	var sql string
+	var args []interface{}
+	switch relation.OnDelete {
+	case schema.ON_DELETE_CASCADE, schema.ON_DELETE_SET_NULL:
+		related, err := engine.schemaOf(relation.Model)
+		if err != nil {
+			return err
+		}
+		_, condition, err := engine.relatedRows(relation, ids)
+		if err != nil {
+			return err
+		}
+		if relation.OnDelete == schema.ON_DELETE_SET_NULL {
+			sql, args, err = engine.dialect.BuildUpdate(related.TableName, map[string]interface{}{relation.Field: nil}, map[string]interface{}{relation.Field + " in": ids})
+		} else if column := related.SoftDeleteColumn(); column != "" {
+			// 关联模型为软删除时只标记删除
+			sql, args, err = engine.dialect.BuildUpdate(related.TableName, map[string]interface{}{column: dialect.Expr{SQL: "CURRENT_TIMESTAMP"}}, condition)
+		} else {
+			_, err = engine.deleteCascade(ctx, related, condition, depth+1, tx)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	case schema.ON_DELETE_DETACH:
+		var err error
+		sql, args, err = engine.dialect.BuildDelete(relation.Pivot.Table, map[string]interface{}{relation.Pivot.LocalKey + " in": ids})
+		if err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	_, err := engine.exec(ctx, sql, args, tx)
+	return err
+}
+
+// relatedRows 关系中关联到ids的数据所在的表及条件，多对多关系为中间表；关联模型为软删除时排除已删除的数据
+func (engine *Engine) relatedRows(relation *schema.Relation, ids []interface{}) (string, map[string]interface{}, error) {
+	if relation.Type == schema.RELATION_MANY_TO_MANY {
+		return relation.Pivot.Table, map[string]interface{}{relation.Pivot.LocalKey + " in": ids}, nil
+	}
+	related, err := engine.schemaOf(relation.Model)
+	if err != nil {
+		return "", nil, err
+	}
+	condition := map[string]interface{}{relation.Field + " in": ids}
+	if column := related.SoftDeleteColumn(); column != "" {
+		condition[column] = nil
+	}
+	return related.TableName, condition, nil
+}
+
+// ForeignKeySQL 返回模型的hasOne/hasMany关系中constraint为true时，在关联模型的表上建立外键约束的语句，不会访问数据库
+// 关联模型的表及本模型的表都建立后才能执行，sqlite不支持为已有的表添加外键约束，返回空
+func (engine *Engine) ForeignKeySQL(name string) ([]string, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := engine.foreignKeys(s)
+	if err != nil {
+		return nil, err
+	}
+	var sqls []string
+	for _, key := range keys {
+		sqls = append(sqls, key.sql)
+	}
+	return sqls, nil
+}
+
+// foreignKey 建立在关联模型的表上的外键约束
+type foreignKey struct {
+	table string
+	name  string
+	sql   string
+}
+
+func (engine *Engine) foreignKeys(s *schema.Schema) ([]foreignKey, error) {
+	pk := s.PrimaryKey()
+	var keys []foreignKey
+	for _, relation := range s.Relations {
+		if !relation.Constraint || pk == nil {
+			continue
+		}
+		related, err := engine.schemaOf(relation.Model)
+		if err != nil {
+			return nil, err
+		}
+		if sql := engine.dialect.ForeignKeySQL(related.TableName, relation.Field, s.TableName, pk.Column, relation.OnDelete); sql != "" {
+			keys = append(keys, foreignKey{
+				table: related.TableName,
+				name:  dialect.ForeignKeyName(related.TableName, relation.Field),
+				sql:   sql,
+			})
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"strings"
+	"testing"
+)
+
+const writerDef = `{
+  "code": "writer",
+  "name": "作者",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "名称", "name": "name", "type": "string"}
+  ],
+  "relations": [
+    {"name": "articles", "type": "hasMany", "model": "article", "field": "writer_id", "onDelete": "cascade", "constraint": true},
+    {"name": "drafts", "type": "hasMany", "model": "draft", "field": "writer_id", "onDelete": "setNull"},
+    {"name": "awards", "type": "hasMany", "model": "award", "field": "writer_id", "onDelete": "restrict"},
+    {"name": "badges", "type": "manyToMany", "model": "role", "pivot": {"table": "writer_badge", "foreign_key": "role_id", "local_key": "writer_id"}, "onDelete": "detach"}
+  ]
+}`
+
+const articleDef = `{
+  "code": "article",
+  "name": "文章",
+  "options": {"softDelete": true},
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "标题", "name": "title", "type": "string"},
+    {"label": "作者", "name": "writerId", "type": "int64"},
+    {"label": "删除时间", "name": "deletedAt", "type": "datetime"}
+  ]
+}`
+
+const draftDef = `{
+  "code": "draft",
+  "name": "草稿",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "作者", "name": "writerId", "type": "int64"}
+  ]
+}`
+
+const awardDef = `{
+  "code": "award",
+  "name": "奖项",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "作者", "name": "writerId", "type": "int64"}
+  ]
+}`
+
+func TestEngine_CascadeSQL(t *testing.T) {
+	Convey("删除时的关联处理", t, func() {
+		engine := newTestEngine(roleDef, writerDef, articleDef, draftDef, awardDef)
+
+		Convey("外键约束", func() {
+			sqls, err := engine.ForeignKeySQL("writer")
+			So(err, ShouldBeNil)
+			So(sqls, ShouldResemble, []string{
+				"ALTER TABLE `article` ADD CONSTRAINT `FK_ARTICLE_WRITER_ID` FOREIGN KEY (`writer_id`) REFERENCES `writer` (`id`) ON DELETE CASCADE",
+			})
+			sqls, err = engine.ForeignKeySQL("role")
+			So(err, ShouldBeNil)
+			So(sqls, ShouldBeEmpty)
+		})
+
+		Convey("有关联处理时在事务中删除", func() {
+			_, err := engine.Delete("writer", map[string]interface{}{"id": 1})
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+
+		Convey("没有条件时拒绝", func() {
+			_, err := engine.Delete("writer", nil)
+			So(err, ShouldEqual, dialect.ErrEmptyWhere)
+		})
+	})
+}
+
+const folderDef = `{
+  "code": "folder",
+  "name": "目录",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"}
+  ],
+  "relations": [
+    {"name": "drafts", "type": "hasMany", "model": "draft", "field": "writer_id", "onDelete": "cascade"}
+  ]
+}`
+
+// idRows 按表名返回主键查询的结果，COUNT查询返回count
+func idRows(count int64, ids map[string][]int64) func(sql string, args []driver.NamedValue) *fakeRows {
+	return func(sql string, args []driver.NamedValue) *fakeRows {
+		if strings.HasPrefix(sql, "SELECT COUNT(*)") {
+			return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{count}}}
+		}
+		for table, values := range ids {
+			if strings.Contains(sql, "FROM `"+table+"`") {
+				rows := &fakeRows{columns: []string{"id"}}
+				for _, id := range values {
+					rows.values = append(rows.values, []driver.Value{id})
+				}
+				return rows
+			}
+		}
+		return nil
+	}
+}
+
+func TestEngine_CascadeStatements(t *testing.T) {
+	Convey("删除时执行的语句", t, func() {
+		Convey("依次处理restrict、cascade、setNull及detach后删除", func() {
+			engine, fake := newFakeEngine(roleDef, writerDef, articleDef, draftDef, awardDef)
+			fake.query = idRows(0, map[string][]int64{"writer": {1, 2}})
+			n, err := engine.Delete("writer", map[string]interface{}{"name": "x"})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SELECT `id` FROM `writer` WHERE (`name` = 'x')",
+				"SELECT COUNT(*) FROM `award` WHERE (`writer_id` IN (1, 2))",
+				"UPDATE `article` SET `deleted_at`=CURRENT_TIMESTAMP WHERE ((`deleted_at` IS NULL) AND (`writer_id` IN (1, 2)))",
+				"UPDATE `draft` SET `writer_id`=NULL WHERE (`writer_id` IN (1, 2))",
+				"DELETE `writer_badge` FROM `writer_badge` WHERE (`writer_id` IN (1, 2))",
+				"DELETE `writer` FROM `writer` WHERE (`id` IN (1, 2))",
+				"COMMIT",
+			})
+		})
+
+		Convey("关联数据不是软删除时递归删除", func() {
+			engine, fake := newFakeEngine(folderDef, draftDef)
+			fake.query = idRows(0, map[string][]int64{"folder": {1}, "draft": {7, 8}})
+			_, err := engine.Delete("folder", map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"BEGIN",
+				"DELETE `draft` FROM `draft` WHERE (`id` IN (7, 8))",
+				"DELETE `folder` FROM `folder` WHERE (`id` IN (1))",
+				"COMMIT",
+			})
+		})
+
+		Convey("restrict关系有关联数据时回滚", func() {
+			engine, fake := newFakeEngine(roleDef, writerDef, articleDef, draftDef, awardDef)
+			fake.query = idRows(3, map[string][]int64{"writer": {1}})
+			_, err := engine.Delete("writer", map[string]interface{}{"id": 1})
+			So(errors.Is(err, ErrDeleteRestricted), ShouldBeTrue)
+			So(writes(fake.statements()), ShouldResemble, []string{"BEGIN", "ROLLBACK"})
+		})
+
+		Convey("没有符合条件的数据时不删除", func() {
+			engine, fake := newFakeEngine(roleDef, writerDef, articleDef, draftDef, awardDef)
+			n, err := engine.Delete("writer", map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(writes(fake.statements()), ShouldResemble, []string{"BEGIN", "COMMIT"})
+		})
+	})
+}
@@ -2,16 +2,27 @@ package dialect
 
 import (
 	"errors"
+	"fmt"
 	"github.com/yaochi-tech/goqu"
 	"github.com/yaochi-tech/lingquan-core-go/db/schema"
 	"github.com/yaochi-tech/lingquan-core-go/util"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 var (
 	ErrDialectNotSupported error = errors.New("dialect not supported")
+	ErrInvalidCondition    error = errors.New("invalid condition")
+)
+
+// 内置支持的方言名称，与goqu的方言名称一致
+const (
+	MYSQL     = "mysql"
+	POSTGRES  = "postgres"
+	SQLITE3   = "sqlite3"
+	SQLSERVER = "sqlserver"
 )
 
 var dialectsMap = map[string]Dialect{}
@@ -23,11 +34,44 @@ type Dialect interface {
 	TableExistSQL(tableName, dbName string) (string, []interface{})
 	CreateTableSQL(schema *schema.Schema) string
 	DropTableSQL(schema *schema.Schema) string
+	// FulltextIndexSQL 返回建立全文索引的sql语句，在建表之后依次执行
+	FulltextIndexSQL(schema *schema.Schema) []string
+	// IndexSQL 返回建立普通索引的sql语句，在建表之后依次执行
+	IndexSQL(schema *schema.Schema) []string
+	// FulltextIndexStatements 按索引分组返回FulltextIndexSQL的语句，迁移时逐个检查索引是否存在
+	FulltextIndexStatements(schema *schema.Schema) []IndexStatement
+	// IndexStatements 按索引分组返回IndexSQL的语句，迁移时逐个检查索引是否存在
+	IndexStatements(schema *schema.Schema) []IndexStatement
+	// UniqueIndexStatements 返回唯一约束对应的唯一索引语句，迁移已存在的表时逐个检查索引是否存在
+	UniqueIndexStatements(schema *schema.Schema) []IndexStatement
+	// IndexExistSQL 返回查询索引是否存在的sql语句，sql查询应只有一个字段，索引名称
+	IndexExistSQL(tableName, name, dbName string) (string, []interface{})
+	// ForeignKeySQL 返回建立外键约束的sql语句，不支持时返回空字符串
+	ForeignKeySQL(table, column, refTable, refColumn, onDelete string) string
+	// ForeignKeyExistSQL 返回查询外键约束是否存在的sql语句，不支持外键约束时返回空字符串
+	ForeignKeyExistSQL(tableName, name, dbName string) (string, []interface{})
 
 	BuildInsert(tableName string, dataList []map[string]interface{}) (string, []interface{}, error)
+	// BuildUpsert 插入数据，conflictColumns冲突时更新updateColumns，incrColumns加1
+	BuildUpsert(tableName string, dataList []map[string]interface{}, conflictColumns, updateColumns []string, incrColumns ...string) (string, []interface{}, error)
 	BuildSelect(tableName string, selectFields []string, namedCondition map[string]interface{}) (string, []interface{}, error)
 	BuildUpdate(tableName string, updateData, where map[string]interface{}) (string, []interface{}, error)
+	// BuildBulkUpdate 按主键批量更新各行不同的值
+	BuildBulkUpdate(tableName, pkColumn string, dataList []map[string]interface{}) (string, []interface{}, error)
 	BuildDelete(tableName string, where map[string]interface{}) (string, []interface{}, error)
+	// BuildCount 统计更新或删除将要影响的行数
+	BuildCount(tableName string, updateData, where map[string]interface{}) (string, []interface{}, error)
+	// BuildUpdateTargets 查询并锁定更新将要影响的行的主键
+	BuildUpdateTargets(tableName, pkColumn string, updateData, where map[string]interface{}) (string, []interface{}, error)
+	// Returning 为insert/update语句追加返回写入行的子句，方言不支持时返回false
+	Returning(sql string) (string, bool)
+
+	// SavepointSQL 返回建立保存点的语句，用于嵌套事务
+	SavepointSQL(name string) string
+	// RollbackToSavepointSQL 返回回滚到保存点的语句
+	RollbackToSavepointSQL(name string) string
+	// ReleaseSavepointSQL 返回释放保存点的语句，不支持时返回空字符串
+	ReleaseSavepointSQL(name string) string
 }
 
 func RegisterDialect(name string, dialect Dialect) {
@@ -39,22 +83,37 @@ func GetDialect(name string) (dialect Dialect, ok bool) {
 	return
 }
 
+// DialectWrapper 基于goqu的方言实现，Name为方言名称，用于生成各数据库特有的语句，如建表、json路径等
 type DialectWrapper struct {
+	Name    string
 	Dialect goqu.DialectWrapper
 }
 
+// quote 按照方言引用表名、字段名
+func (m *DialectWrapper) quote(name string) string {
+	if m.Name == POSTGRES || m.Name == SQLSERVER {
+		return `"` + name + `"`
+	}
+	return "`" + name + "`"
+}
+
 func (m *DialectWrapper) CreateTableSQL(schema *schema.Schema) string {
 	var columns []string
 	var primaryKeys []string
 	for _, field := range schema.Fields {
 		columns = append(columns, m.columnSQL(field))
 		if field.IsPrimaryKey {
-			primaryKeys = append(primaryKeys, field.Name)
+			primaryKeys = append(primaryKeys, m.quote(field.Column))
 		}
 	}
 	var sql strings.Builder
-	sql.WriteString("CREATE TABLE IF NOT EXISTS ")
-	sql.WriteString("`" + schema.TableName + "`")
+	if m.Name == SQLSERVER {
+		// sql server不支持IF NOT EXISTS
+		sql.WriteString("IF OBJECT_ID(N'" + schema.TableName + "', N'U') IS NULL CREATE TABLE ")
+	} else {
+		sql.WriteString("CREATE TABLE IF NOT EXISTS ")
+	}
+	sql.WriteString(m.quote(schema.TableName))
 	sql.WriteString(" (")
 	sql.WriteString(strings.Join(columns, ","))
 	if len(primaryKeys) > 0 {
@@ -62,81 +121,175 @@ func (m *DialectWrapper) CreateTableSQL(schema *schema.Schema) string {
 		sql.WriteString(strings.Join(primaryKeys, ","))
 		sql.WriteString(")")
 	}
+	// 唯一约束，upsert时可以作为冲突判断的列
+	for _, index := range schema.UniqueIndexes() {
+		var uniqueKeys []string
+		for _, column := range index.Columns {
+			uniqueKeys = append(uniqueKeys, m.quote(column))
+		}
+		sql.WriteString(", CONSTRAINT " + m.quote(index.Name) + " UNIQUE(")
+		sql.WriteString(strings.Join(uniqueKeys, ","))
+		sql.WriteString(")")
+	}
 	sql.WriteString(")")
 	return sql.String()
 }
 
+// IndexSQL 返回建立普通索引的sql语句，mysql及sql server不支持IF NOT EXISTS，迁移时先检查索引是否存在
+func (m *DialectWrapper) IndexSQL(schema *schema.Schema) []string {
+	return flattenIndexes(m.IndexStatements(schema))
+}
+
+// IndexStatements 按索引分组返回IndexSQL的语句
+func (m *DialectWrapper) IndexStatements(schema *schema.Schema) []IndexStatement {
+	var statements []IndexStatement
+	for _, index := range schema.Indexes() {
+		statements = append(statements, m.indexStatement("CREATE INDEX ", schema.TableName, index.Name, index.Columns))
+	}
+	return statements
+}
+
+// UniqueIndexStatements 返回建立唯一索引的语句，索引名称与建表时的唯一约束一致，用于为已存在的表补建唯一约束
+func (m *DialectWrapper) UniqueIndexStatements(schema *schema.Schema) []IndexStatement {
+	var statements []IndexStatement
+	for _, index := range schema.UniqueIndexes() {
+		statements = append(statements, m.indexStatement("CREATE UNIQUE INDEX ", schema.TableName, index.Name, index.Columns))
+	}
+	return statements
+}
+
+func (m *DialectWrapper) indexStatement(create, table, name string, indexColumns []string) IndexStatement {
+	var columns []string
+	for _, column := range indexColumns {
+		columns = append(columns, m.quote(column))
+	}
+	sql := create
+	if m.Name == POSTGRES || m.Name == SQLITE3 {
+		sql += "IF NOT EXISTS "
+	}
+	sql += m.quote(name) + " ON " + m.quote(table) + " (" + strings.Join(columns, ",") + ")"
+	return IndexStatement{Name: name, SQL: []string{sql}}
+}
+
 func (m *DialectWrapper) DropTableSQL(schema *schema.Schema) string {
-	return "DROP TABLE IF EXISTS `" + schema.TableName + "`"
+	return "DROP TABLE IF EXISTS " + m.quote(schema.TableName)
 }
 
 func (m *DialectWrapper) DataTypeOf(typ string) string {
 	t := strings.ToLower(typ)
 	switch t {
 	case "string":
+		if m.Name == SQLSERVER {
+			return "nvarchar"
+		}
 		return "varchar"
 	case "text":
+		if m.Name == SQLSERVER {
+			return "nvarchar(max)"
+		}
 		return "text"
 	case "id", "int64", "uint64":
 		return "bigint"
 	case "int", "uint":
 		return "int"
 	case "bool":
+		switch m.Name {
+		case POSTGRES:
+			return "boolean"
+		case SQLSERVER:
+			return "bit"
+		}
 		return "bool"
-	case "float32", "float64":
+	case "float", "float32":
+		if m.Name == POSTGRES {
+			return "real"
+		}
 		return "float"
+	case "double", "float64":
+		switch m.Name {
+		case POSTGRES:
+			return "double precision"
+		case SQLSERVER:
+			return "float"
+		}
+		return "double"
 	case "date", "datetime":
+		switch m.Name {
+		case POSTGRES:
+			return "timestamp"
+		case SQLSERVER:
+			return "datetime2"
+		}
 		return "datetime"
 	case "json":
+		switch m.Name {
+		case POSTGRES:
+			return "jsonb"
+		case SQLITE3:
+			// sqlite的json函数处理的是文本
+			return "text"
+		case SQLSERVER:
+			return "nvarchar(max)"
+		}
 		return "json"
 	}
 	panic("invalid sql type " + typ)
 }
 
 func (m *DialectWrapper) CurrentDatabaseSQL() string {
+	switch m.Name {
+	case POSTGRES:
+		return "SELECT current_database()"
+	case SQLITE3:
+		return "SELECT 'main'"
+	case SQLSERVER:
+		return "SELECT DB_NAME()"
+	}
 	return "SELECT DATABASE()"
 }
 
 func (m *DialectWrapper) TableExistSQL(tableName, dbName string) (string, []interface{}) {
+	switch m.Name {
+	case POSTGRES:
+		args := []interface{}{tableName, dbName}
+		return "SELECT table_name FROM information_schema.tables WHERE table_name = ? AND table_catalog = ? AND table_schema = current_schema()", args
+	case SQLITE3:
+		args := []interface{}{tableName}
+		return "SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", args
+	case SQLSERVER:
+		args := []interface{}{tableName, dbName}
+		return "SELECT TABLE_NAME FROM information_schema.tables WHERE TABLE_NAME = ? AND TABLE_CATALOG = ?", args
+	}
 	args := []interface{}{tableName, dbName}
 	return "SELECT TABLE_NAME FROM information_schema.tables WHERE TABLE_NAME = ? AND TABLE_SCHEMA = ? ", args
 }
 
 func (m *DialectWrapper) columnSQL(field *schema.Field) string {
 	var sql strings.Builder
-	sql.WriteString("`" + field.Name + "`")
+	sql.WriteString(m.quote(field.Column))
 
-	if field.Type == "string" {
+	typ := m.DataTypeOf(field.ValueType())
+	if typ == "varchar" || typ == "nvarchar" {
 		length := "255"
+		if field.IsPrimaryKey {
+			// 字符串主键，ulid为26位，uuid为36位
+			length = "36"
+		}
 		if field.Length > 0 {
 			length = strconv.Itoa(int(field.Length))
 		}
-		sql.WriteString(" varchar(" + length + ")")
-	} else if field.Type == "id" {
-		sql.WriteString(" bigint")
-	} else if field.Type == "int" {
-		sql.WriteString(" int")
-	} else if field.Type == "bool" {
-		sql.WriteString(" bool")
-	} else if field.Type == "float32" {
-		sql.WriteString(" float")
-	} else if field.Type == "float64" {
-		sql.WriteString(" double")
-	} else if field.Type == "date" {
-		sql.WriteString(" datetime")
-	} else if field.Type == "datetime" {
-		sql.WriteString(" datetime")
-	} else if field.Type == "json" {
-		sql.WriteString(" json")
-	} else if field.Type == "text" {
-		sql.WriteString(" text")
+		typ += "(" + length + ")"
 	}
+	sql.WriteString(" " + typ)
 
 	if field.IsPrimaryKey || field.NotNull {
 		sql.WriteString(" NOT NULL")
 	}
 	if field.Default != "" {
-		if field.IsDefaultRaw || (field.Type != "string" && field.Type != "json") {
+		if m.Name == SQLSERVER && field.Type == "bool" && !field.IsDefaultRaw {
+			// sql server的bit类型使用1/0
+			sql.WriteString(" DEFAULT " + map[bool]string{true: "1", false: "0"}[field.Default == "true"])
+		} else if field.IsDefaultRaw || (field.Type != "string" && field.Type != "json") {
 			sql.WriteString(" DEFAULT " + field.Default)
 		} else {
 			sql.WriteString(" DEFAULT '" + field.Default + "'")
@@ -159,211 +312,258 @@ func (m *DialectWrapper) BuildInsert(tableName string, dataList []map[string]int
 }
 
 func (m *DialectWrapper) BuildSelect(tableName string, selectFields []string, where map[string]interface{}) (string, []interface{}, error) {
-	// selectFields转蛇形命名
-	var snakeSelectFields []interface{}
-	for _, field := range selectFields {
-		snakeSelectFields = append(snakeSelectFields, util.ToSnake(field))
-	}
-
-	ds := m.Dialect.From(tableName).Select(snakeSelectFields...)
+	// 条件中的$join为关联查询需要连接的表，selectFields会转为蛇形命名
+	sc := m.newScope(tableName, where)
+	ds := m.Dialect.From(tableName).Select(sc.selects(selectFields)...)
+	ds = sc.join(ds)
 	// where要处理成goqu的where语句
-	var whereExList []goqu.Expression
-	whereExList = whereExpression(where)
-
-	ds = ds.Where(whereExList...)
+	whereExList, err := whereExpression(where, sc)
+	if err != nil {
+		return "", nil, err
+	}
+	if ds, err = sc.options(ds.Where(whereExList...), where); err != nil {
+		return "", nil, err
+	}
+	if v, ok := where[OP_LOCK]; ok {
+		// 行锁，如：FOR UPDATE
+		if ds, err = sc.lock(ds, v); err != nil {
+			return "", nil, err
+		}
+	}
+	return ds.ToSQL()
+}
 
-	// 对where中的特殊操作进行处理
+// options 对where中的特殊操作进行处理，如：分页、排序、分组
+func (sc *scope) options(ds *goqu.SelectDataset, where map[string]interface{}) (*goqu.SelectDataset, error) {
 	for k, v := range where {
 		switch k {
 		case OP_LIMIT:
 			// v应该是一个正整数
-			if reflect.TypeOf(v).Kind() == reflect.Uint {
-				i := v.(uint)
+			if i, ok := toUint(v); ok {
 				ds = ds.Limit(i)
 			}
 		case OP_OFFSET:
 			// v应该是一个正整数
-			if reflect.TypeOf(v).Kind() == reflect.Uint {
-				i := v.(uint)
+			if i, ok := toUint(v); ok {
 				ds = ds.Offset(i)
 			}
 		case OP_ORDER_BY:
-			// v应该是一个string或数组[]string
-			if reflect.TypeOf(v).Kind() == reflect.String {
-				s := v.(string)
-				columnWithOrder := strings.Split(s, " ")
-				column := util.ToSnake(columnWithOrder[0])
+			// v应该是一个string或数组[]string，$relevance表示按全文检索的相关度倒序
+			for _, co := range toStrings(v) {
+				if co == OP_RELEVANCE {
+					if sc.distinct() {
+						return nil, fmt.Errorf("%w: %s cannot order by %s when joining a to-many relation", ErrInvalidCondition, k, co)
+					}
+					ds = sc.orderByRelevance(ds)
+					continue
+				}
+				columnWithOrder := strings.Split(co, " ")
+				if !sc.orderable(columnWithOrder[0]) {
+					return nil, fmt.Errorf("%w: %s cannot order by %s when joining a to-many relation, only selected fields are allowed", ErrInvalidCondition, k, columnWithOrder[0])
+				}
+				column := sc.column(columnWithOrder[0], nil)
 				if len(columnWithOrder) == 2 {
 					if strings.ToLower(columnWithOrder[1]) == "desc" {
-						ds = ds.Order(goqu.C(column).Desc())
+						ds = ds.OrderAppend(column.Desc())
 					} else {
-						ds = ds.Order(goqu.C(column).Asc())
+						ds = ds.OrderAppend(column.Asc())
 					}
 				} else {
-					ds = ds.Order(goqu.C(column).Asc())
-				}
-			} else if reflect.TypeOf(v).Kind() == reflect.Slice {
-				s := v.([]string)
-				for _, co := range s {
-					columnWithOrder := strings.Split(co, " ")
-					column := util.ToSnake(columnWithOrder[0])
-					if len(columnWithOrder) == 2 {
-						if strings.ToLower(columnWithOrder[1]) == "desc" {
-							ds = ds.Order(goqu.C(column).Desc())
-						} else {
-							ds = ds.Order(goqu.C(column).Asc())
-						}
-					} else {
-						ds = ds.Order(goqu.C(column).Asc())
-					}
+					ds = ds.OrderAppend(column.Asc())
 				}
 			}
 		case OP_GROUP_BY:
 			// v应该是一个string或数组[]string
-			if reflect.TypeOf(v).Kind() == reflect.String {
-				s := v.(string)
-				column := util.ToSnake(s)
-				ds = ds.GroupBy(column)
-			} else if reflect.TypeOf(v).Kind() == reflect.Slice {
-				s := v.([]string)
-				for _, co := range s {
-					column := util.ToSnake(co)
-					ds = ds.GroupBy(column)
-				}
+			for _, co := range toStrings(v) {
+				ds = ds.GroupByAppend(sc.column(co, nil))
 			}
 		case OP_HAVING:
-			// v应该是一个map[string]interface{}，否则忽略
-			if reflect.TypeOf(v).Kind() == reflect.Map {
-				// v转为map[string]interface{}
-				s := v.(map[string]interface{})
-				// 递归调用whereExpression
-				ds = ds.Having(whereExpression(s)...)
+			// v应该是一个map[string]interface{}
+			exList, err := logicalExpression(k, v, sc)
+			if err != nil {
+				return nil, err
 			}
+			ds = ds.Having(exList...)
 		}
 	}
-
-	return ds.ToSQL()
+	if _, ok := where[OP_ORDER_BY]; !ok && !sc.distinct() {
+		// 有全文检索且未指定排序时，按相关度倒序；去重查询的排序字段必须在查询字段中，不按相关度排序
+		ds = sc.orderByRelevance(ds)
+	}
+	return ds, nil
 }
 
-func whereExpression(m map[string]interface{}) []goqu.Expression {
+// whereExpression 将条件map转为goqu的条件表达式，sc用于解析字段对应的表及json路径
+// 无法解析的条件返回ErrInvalidCondition，不能忽略，否则更新、删除的范围会扩大
+func whereExpression(m map[string]interface{}, sc *scope) ([]goqu.Expression, error) {
 	var whereExList []goqu.Expression
-	for k, v := range m {
-		k = util.ToSnake(k)
+	// 按key排序，保证生成的sql稳定
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := m[k]
+		if sq, ok := v.(SubQuery); ok {
+			ex, err := sc.subQueryExpression(k, sq)
+			if err != nil {
+				return nil, err
+			}
+			whereExList = append(whereExList, ex)
+			continue
+		}
+		if strings.HasPrefix(k, "$") {
+			// $or/$and可以直接作为key使用，其他特殊操作符在BuildSelect中处理
+			switch k {
+			case OP_OR, OP_AND:
+				exList, err := logicalExpression(k, v, sc)
+				if err != nil {
+					return nil, err
+				}
+				whereExList = append(whereExList, combine(k, exList))
+			}
+			continue
+		}
 		if !strings.Contains(k, " ") {
 			// 如果v不是数组
-			if reflect.TypeOf(v).Kind() == reflect.Slice {
-				// v转为数组[]interface{}
-				s := v.([]interface{})
-				whereExList = append(whereExList, goqu.C(k).In(s...))
+			if s, ok := toSlice(v); ok {
+				whereExList = append(whereExList, sc.column(k, v).In(s...))
 			} else {
-				whereExList = append(whereExList, goqu.C(k).Eq(v))
+				whereExList = append(whereExList, sc.column(k, v).Eq(v))
 			}
 		} else {
-			splited := strings.Split(k, " ")
-			if len(splited) != 2 {
-				continue // 忽略
-			}
+			// 操作符可能包含空格，如：name not like
+			splited := strings.SplitN(k, " ", 2)
 			op := strings.ToLower(splited[1])
 			switch op {
+			case OP_MATCH:
+				whereExList = append(whereExList, sc.match(splited[0], v))
+			case OP_CONTAINS:
+				ex, err := sc.contains(splited[0], v)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCondition, k, err)
+				}
+				whereExList = append(whereExList, ex)
 			case OP_IN:
 				// 如果v不是数组
-				if reflect.TypeOf(v).Kind() == reflect.Slice {
-					// v转为数组[]interface{}
-					s := v.([]interface{})
-					whereExList = append(whereExList, goqu.C(splited[0]).In(s...))
+				if s, ok := toSlice(v); ok {
+					whereExList = append(whereExList, sc.column(splited[0], v).In(s...))
 				} else {
-					whereExList = append(whereExList, goqu.C(splited[0]).Eq(v))
+					whereExList = append(whereExList, sc.column(splited[0], v).Eq(v))
+				}
+			case OP_NOT_IN:
+				if s, ok := toSlice(v); ok {
+					whereExList = append(whereExList, sc.column(splited[0], v).NotIn(s...))
+				} else {
+					whereExList = append(whereExList, sc.column(splited[0], v).Neq(v))
 				}
 			case OP_LIKE:
-				whereExList = append(whereExList, goqu.C(splited[0]).Like(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Like(v))
 			case OP_NOT_LIKE:
-				whereExList = append(whereExList, goqu.C(splited[0]).NotLike(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).NotLike(v))
 			case OP_BETWEEN:
-				// 如果v不是数组
-				if reflect.TypeOf(v).Kind() == reflect.Slice {
-					// v转为数组[]interface{}
-					s := v.([]interface{})
-					if len(s) == 2 {
-						whereExList = append(whereExList, goqu.C(splited[0]).Between(goqu.Range(s[0], s[1])))
-					}
+				// v应该是包含两个值的数组
+				s, ok := toSlice(v)
+				if !ok || len(s) != 2 {
+					return nil, fmt.Errorf("%w: %s requires two values, got %v", ErrInvalidCondition, k, v)
 				}
+				whereExList = append(whereExList, sc.column(splited[0], v).Between(goqu.Range(s[0], s[1])))
 			case OP_NOT_BETWEEN:
-				// 如果v不是数组
-				if reflect.TypeOf(v).Kind() == reflect.Slice {
-					// v转为数组[]interface{}
-					s := v.([]interface{})
-					if len(s) == 2 {
-						whereExList = append(whereExList, goqu.C(splited[0]).NotBetween(goqu.Range(s[0], s[1])))
-					}
+				s, ok := toSlice(v)
+				if !ok || len(s) != 2 {
+					return nil, fmt.Errorf("%w: %s requires two values, got %v", ErrInvalidCondition, k, v)
 				}
+				whereExList = append(whereExList, sc.column(splited[0], v).NotBetween(goqu.Range(s[0], s[1])))
 			case OP_IS_NULL:
-				whereExList = append(whereExList, goqu.C(splited[0]).IsNull())
+				whereExList = append(whereExList, sc.column(splited[0], v).IsNull())
 			case OP_IS_NOT_NULL:
-				whereExList = append(whereExList, goqu.C(splited[0]).IsNotNull())
+				whereExList = append(whereExList, sc.column(splited[0], v).IsNotNull())
 			case OP_EQ:
-				whereExList = append(whereExList, goqu.C(splited[0]).Eq(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Eq(v))
 			case OP_NEQ:
-				whereExList = append(whereExList, goqu.C(splited[0]).Neq(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Neq(v))
 			case OP_GT:
-				whereExList = append(whereExList, goqu.C(splited[0]).Gt(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Gt(v))
 			case OP_GTE:
-				whereExList = append(whereExList, goqu.C(splited[0]).Gte(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Gte(v))
 			case OP_LT:
-				whereExList = append(whereExList, goqu.C(splited[0]).Lt(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Lt(v))
 			case OP_LTE:
-				whereExList = append(whereExList, goqu.C(splited[0]).Lte(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Lte(v))
 			case OP_IS:
 				// 判断值是bool
-				if reflect.TypeOf(v).Kind() == reflect.Bool {
-					whereExList = append(whereExList, goqu.C(splited[0]).Is(v))
+				if _, ok := v.(bool); ok {
+					whereExList = append(whereExList, sc.column(splited[0], v).Is(v))
 				} else {
-					whereExList = append(whereExList, goqu.C(splited[0]).Eq(v))
+					whereExList = append(whereExList, sc.column(splited[0], v).Eq(v))
 				}
-			case OP_OR:
-				// v应该是一个map[string]interface{}，否则忽略
-				if reflect.TypeOf(v).Kind() == reflect.Map {
-					// v转为map[string]interface{}
-					s := v.(map[string]interface{})
-					// 递归调用whereExpression
-					whereExList = append(whereExList, goqu.Or(whereExpression(s)...))
-				}
-			case OP_AND:
-				// v应该是一个map[string]interface{}，否则忽略
-				if reflect.TypeOf(v).Kind() == reflect.Map {
-					// v转为map[string]interface{}
-					s := v.(map[string]interface{})
-					// 递归调用whereExpression
-					whereExList = append(whereExList, goqu.And(whereExpression(s)...))
+			case OP_OR, OP_AND:
+				// v应该是一个map[string]interface{}
+				exList, err := logicalExpression(op, v, sc)
+				if err != nil {
+					return nil, err
 				}
+				whereExList = append(whereExList, combine(op, exList))
 			default:
-				whereExList = append(whereExList, goqu.C(splited[0]).Eq(v))
+				whereExList = append(whereExList, sc.column(splited[0], v).Eq(v))
 			}
 		}
 	}
-	return whereExList
+	return whereExList, nil
+}
+
+// logicalExpression 解析$or/$and/$having的值，v必须是条件map
+func logicalExpression(op string, v interface{}, sc *scope) ([]goqu.Expression, error) {
+	s, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s requires a condition map, got %T", ErrInvalidCondition, op, v)
+	}
+	// 递归调用whereExpression
+	return whereExpression(s, sc)
+}
+
+// combine 按$or/$and组合条件
+func combine(op string, exList []goqu.Expression) goqu.Expression {
+	if op == OP_OR {
+		return goqu.Or(exList...)
+	}
+	return goqu.And(exList...)
 }
 
 func (m *DialectWrapper) BuildUpdate(tableName string, updateData, where map[string]interface{}) (string, []interface{}, error) {
 	// where要处理成goqu的where语句
-	whereExList := whereExpression(where)
+	whereExList, err := whereExpression(where, m.newScope(tableName, nil))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := requireWhere(where, whereExList); err != nil {
+		return "", nil, err
+	}
 
 	ds := m.Dialect.Update(tableName)
-	// updateData中的key转蛇形命名
-	var snakeUpdateData map[string]interface{}
-	for k, v := range updateData {
-		snakeUpdateData[util.ToSnake(k)] = v
+	// updateData中的key转蛇形命名，处理增减操作符
+	record, guards, err := updateRecord(updateData)
+	if err != nil {
+		return "", nil, err
 	}
 
-	ds = ds.Set(snakeUpdateData)
+	ds = ds.Set(record)
 
-	ds = ds.Where(whereExList...)
+	ds = ds.Where(append(whereExList, guards...)...)
 
 	return ds.ToSQL()
 }
 
 func (m *DialectWrapper) BuildDelete(tableName string, where map[string]interface{}) (string, []interface{}, error) {
 	// where要处理成goqu的where语句
-	whereExList := whereExpression(where)
+	whereExList, err := whereExpression(where, m.newScope(tableName, nil))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := requireWhere(where, whereExList); err != nil {
+		return "", nil, err
+	}
 
 	ds := m.Dialect.Delete(tableName)
 
@@ -371,3 +571,56 @@ func (m *DialectWrapper) BuildDelete(tableName string, where map[string]interfac
 
 	return ds.ToSQL()
 }
+
+// toUint 将$limit/$offset的值转换为uint，json解析的数字为float64
+func toUint(v interface{}) (uint, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(rv.Uint()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() >= 0 {
+			return uint(rv.Int()), true
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f >= 0 && f == float64(uint(f)) {
+			return uint(f), true
+		}
+	}
+	return 0, false
+}
+
+// toSlice 将任意类型的切片转换为[]interface{}
+func toSlice(v interface{}) ([]interface{}, bool) {
+	if s, ok := v.([]interface{}); ok {
+		return s, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, true
+}
+
+// toStrings 将string、[]string或[]interface{}转换为[]string
+func toStrings(v interface{}) []string {
+	switch s := v.(type) {
+	case string:
+		return []string{s}
+	case []string:
+		return s
+	case []interface{}:
+		var res []string
+		for _, e := range s {
+			if str, ok := e.(string); ok {
+				res = append(res, str)
+			}
+		}
+		return res
+	}
+	return nil
+}
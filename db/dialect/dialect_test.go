@@ -0,0 +1,899 @@
+package dialect_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	_ "github.com/yaochi-tech/lingquan-core-go/db/dialect/mysql"
+	_ "github.com/yaochi-tech/lingquan-core-go/db/dialect/postgres"
+	_ "github.com/yaochi-tech/lingquan-core-go/db/dialect/sqlite3"
+	_ "github.com/yaochi-tech/lingquan-core-go/db/dialect/sqlserver"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+func TestDialectWrapper_BuildSelect(t *testing.T) {
+	d, ok := dialect.GetDialect("mysql")
+	if !ok {
+		t.Fatal("mysql dialect not registered")
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+		where  map[string]interface{}
+		want   string
+	}{
+		{
+			"等值与in条件",
+			[]string{"id", "userName"},
+			map[string]interface{}{"userName": "test", "id": []interface{}{1, 2}},
+			"SELECT `id`, `user_name` FROM `user` WHERE ((`id` IN (1, 2)) AND (`user_name` = 'test'))",
+		},
+		{
+			"包含空格的操作符",
+			nil,
+			map[string]interface{}{"name not like": "%a%", "age is null": true},
+			"SELECT * FROM `user` WHERE ((`age` IS NULL) AND (`name` NOT LIKE BINARY '%a%'))",
+		},
+		{
+			"$or条件",
+			[]string{"id"},
+			map[string]interface{}{"$or": map[string]interface{}{"id": 1, "name": "a"}},
+			"SELECT `id` FROM `user` WHERE ((`id` = 1) OR (`name` = 'a'))",
+		},
+		{
+			"json解析的分页及排序",
+			[]string{"id"},
+			map[string]interface{}{"$limit": float64(10), "$offset": 20, "$order_by": []interface{}{"id desc", "name"}},
+			"SELECT `id` FROM `user` ORDER BY `id` DESC, `name` ASC LIMIT 10 OFFSET 20",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildSelect("user", tt.fields, tt.where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_JSONPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		fields  []string
+		where   map[string]interface{}
+		want    string
+	}{
+		{
+			"mysql路径比较",
+			dialect.MYSQL,
+			[]string{"id", "profile.address.city"},
+			map[string]interface{}{"profile.address.city": "北京", "profile.age >": 18},
+			"SELECT `id`, JSON_EXTRACT(`profile`, '$.address.city') AS `profile.address.city` FROM `user` " +
+				"WHERE ((JSON_UNQUOTE(JSON_EXTRACT(`profile`, '$.address.city')) = '北京') AND (JSON_UNQUOTE(JSON_EXTRACT(`profile`, '$.age')) > 18))",
+		},
+		{
+			"mysql包含",
+			dialect.MYSQL,
+			[]string{"id"},
+			map[string]interface{}{"tags contains": "go", "profile.roles contains": []interface{}{"admin"}},
+			"SELECT `id` FROM `user` WHERE (JSON_CONTAINS(`profile`, '[\\\"admin\\\"]', '$.roles') AND JSON_CONTAINS(`tags`, '\\\"go\\\"'))",
+		},
+		{
+			"postgres路径比较",
+			dialect.POSTGRES,
+			[]string{"profile.address.city"},
+			map[string]interface{}{"profile.address.city": "北京", "profile.scores.0 >=": 60, "$order_by": "profile.homeAddress desc"},
+			`SELECT ("profile" #> '{address,city}') AS "profile.address.city" FROM "user" ` +
+				`WHERE ((("profile" #>> '{address,city}') = '北京') AND (("profile" #>> '{scores,0}')::numeric >= 60)) ` +
+				`ORDER BY ("profile" #>> '{homeAddress}') DESC`,
+		},
+		{
+			"postgres包含",
+			dialect.POSTGRES,
+			[]string{"id"},
+			map[string]interface{}{"tags contains": "go"},
+			`SELECT "id" FROM "user" WHERE "tags" @> '"go"'::jsonb`,
+		},
+		{
+			"sqlite路径比较及包含",
+			dialect.SQLITE3,
+			[]string{"id"},
+			map[string]interface{}{"profile.address.city": "北京", "tags contains": []interface{}{"a", "b"}},
+			"SELECT `id` FROM `user` WHERE ((json_extract(`profile`, '$.address.city') = '北京') AND " +
+				"(EXISTS (SELECT 1 FROM json_each(`tags`, '$') WHERE value = 'a') AND EXISTS (SELECT 1 FROM json_each(`tags`, '$') WHERE value = 'b')))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := dialect.GetDialect(tt.dialect)
+			if !ok {
+				t.Fatalf("%s dialect not registered", tt.dialect)
+			}
+			got, _, err := d.BuildSelect("user", tt.fields, tt.where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_CreateTableSQL(t *testing.T) {
+	s := schema.Parse(`{
+  "code": "userProfile",
+  "fields": [
+    {"name": "id", "type": "ID"},
+    {"name": "nickName", "type": "string", "length": 20, "required": true},
+    {"name": "profile", "type": "json"},
+    {"name": "enabled", "type": "bool", "default": true},
+    {"name": "email", "type": "string", "unique": true}
+  ]
+}`)
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{
+			dialect.MYSQL,
+			"CREATE TABLE IF NOT EXISTS `user_profile` (`id` bigint NOT NULL,`nick_name` varchar(20) NOT NULL,`profile` json,`enabled` bool DEFAULT true,`email` varchar(255), PRIMARY KEY(`id`), CONSTRAINT `UNI_USER_PROFILE_EMAIL` UNIQUE(`email`))",
+		},
+		{
+			dialect.POSTGRES,
+			`CREATE TABLE IF NOT EXISTS "user_profile" ("id" bigint NOT NULL,"nick_name" varchar(20) NOT NULL,"profile" jsonb,"enabled" boolean DEFAULT true,"email" varchar(255), PRIMARY KEY("id"), CONSTRAINT "UNI_USER_PROFILE_EMAIL" UNIQUE("email"))`,
+		},
+		{
+			dialect.SQLITE3,
+			"CREATE TABLE IF NOT EXISTS `user_profile` (`id` bigint NOT NULL,`nick_name` varchar(20) NOT NULL,`profile` text,`enabled` bool DEFAULT true,`email` varchar(255), PRIMARY KEY(`id`), CONSTRAINT `UNI_USER_PROFILE_EMAIL` UNIQUE(`email`))",
+		},
+		{
+			dialect.SQLSERVER,
+			`IF OBJECT_ID(N'user_profile', N'U') IS NULL CREATE TABLE "user_profile" ("id" bigint NOT NULL,"nick_name" nvarchar(20) NOT NULL,"profile" nvarchar(max),"enabled" bit DEFAULT 1,"email" nvarchar(255), PRIMARY KEY("id"), CONSTRAINT "UNI_USER_PROFILE_EMAIL" UNIQUE("email"))`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			if got := d.CreateTableSQL(s); got != tt.want {
+				t.Errorf("CreateTableSQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_Fulltext(t *testing.T) {
+	s := schema.Parse(`{
+  "code": "article",
+  "fields": [
+    {"name": "id", "type": "ID"},
+    {"name": "title", "type": "string", "fulltext": "FTS_ARTICLE", "parser": "ngram"},
+    {"name": "content", "type": "text", "fulltext": "FTS_ARTICLE", "parser": "ngram"}
+  ]
+}`)
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	where := map[string]interface{}{"title,content match": "数据库 索引", "id >": 10}
+	tests := []struct {
+		dialect   string
+		wantIndex []string
+		wantQuery string
+	}{
+		{
+			dialect.MYSQL,
+			[]string{"CREATE FULLTEXT INDEX `FTS_ARTICLE` ON `article` (`title`, `content`) WITH PARSER ngram"},
+			"SELECT `id` FROM `article` WHERE ((`id` > 10) AND MATCH (`title`, `content`) AGAINST ('数据库 索引' IN NATURAL LANGUAGE MODE)) " +
+				"ORDER BY MATCH (`title`, `content`) AGAINST ('数据库 索引' IN NATURAL LANGUAGE MODE) DESC",
+		},
+		{
+			dialect.POSTGRES,
+			[]string{`CREATE INDEX IF NOT EXISTS "FTS_ARTICLE" ON "article" USING GIN (to_tsvector('simple', coalesce("title", '') || ' ' || coalesce("content", '')))`},
+			`SELECT "id" FROM "article" WHERE (("id" > 10) AND to_tsvector('simple', coalesce("title", '') || ' ' || coalesce("content", '')) @@ plainto_tsquery('simple', '数据库 索引')) ` +
+				`ORDER BY ts_rank(to_tsvector('simple', coalesce("title", '') || ' ' || coalesce("content", '')), plainto_tsquery('simple', '数据库 索引')) DESC`,
+		},
+		{
+			dialect.SQLITE3,
+			[]string{
+				"CREATE VIRTUAL TABLE IF NOT EXISTS `article_fts` USING fts5(title, content, content='article')",
+				"CREATE TRIGGER IF NOT EXISTS `article_fts_insert` AFTER INSERT ON `article` BEGIN " +
+					"INSERT INTO `article_fts` (rowid, `title`, `content`) VALUES (new.rowid, new.`title`, new.`content`); END",
+				"CREATE TRIGGER IF NOT EXISTS `article_fts_delete` AFTER DELETE ON `article` BEGIN " +
+					"INSERT INTO `article_fts` (`article_fts`, rowid, `title`, `content`) VALUES ('delete', old.rowid, old.`title`, old.`content`); END",
+				"CREATE TRIGGER IF NOT EXISTS `article_fts_update` AFTER UPDATE ON `article` BEGIN " +
+					"INSERT INTO `article_fts` (`article_fts`, rowid, `title`, `content`) VALUES ('delete', old.rowid, old.`title`, old.`content`); " +
+					"INSERT INTO `article_fts` (rowid, `title`, `content`) VALUES (new.rowid, new.`title`, new.`content`); END",
+			},
+			"SELECT `id` FROM `article` WHERE ((`id` > 10) AND `article`.`rowid` IN (SELECT rowid FROM `article_fts` WHERE `article_fts` MATCH '{title content} : \"数据库\" \"索引\"')) " +
+				"ORDER BY (SELECT -rank FROM `article_fts` WHERE `article_fts` MATCH '{title content} : \"数据库\" \"索引\"' AND rowid = `article`.`rowid`) DESC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			if got := d.FulltextIndexSQL(s); !reflect.DeepEqual(got, tt.wantIndex) {
+				t.Errorf("FulltextIndexSQL() = %v, want %v", got, tt.wantIndex)
+			}
+			got, _, err := d.BuildSelect("article", []string{"id"}, where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.wantQuery {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_SubQuery(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	orders := dialect.SubQuery{Table: "order", Field: "userId", Where: map[string]interface{}{"amount >": 100}}
+
+	t.Run("in子查询", func(t *testing.T) {
+		got, _, err := d.BuildSelect("user", []string{"id"}, map[string]interface{}{"id in": orders, "status": 1})
+		if err != nil {
+			t.Fatalf("BuildSelect() error = %v", err)
+		}
+		want := "SELECT `id` FROM `user` WHERE (`id` IN (SELECT `order`.`user_id` FROM `order` WHERE (`order`.`amount` > 100)) AND (`status` = 1))"
+		if got != want {
+			t.Errorf("BuildSelect() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("exists关联子查询", func(t *testing.T) {
+		got, _, err := d.BuildSelect("user", []string{"id"}, map[string]interface{}{"id not exists": orders})
+		if err != nil {
+			t.Fatalf("BuildSelect() error = %v", err)
+		}
+		want := "SELECT `id` FROM `user` WHERE NOT EXISTS (SELECT 1 FROM `order` WHERE ((`order`.`amount` > 100) AND (`order`.`user_id` = `user`.`id`)))"
+		if got != want {
+			t.Errorf("BuildSelect() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("同一个表的子查询使用别名", func(t *testing.T) {
+		managers := dialect.SubQuery{Table: "user", Field: "managerId", Where: map[string]interface{}{"$limit": 10}}
+		got, _, err := d.BuildSelect("user", []string{"id"}, map[string]interface{}{"id exists": managers})
+		if err != nil {
+			t.Fatalf("BuildSelect() error = %v", err)
+		}
+		want := "SELECT `id` FROM `user` WHERE EXISTS (SELECT 1 FROM `user` AS `user_1` WHERE (`user_1`.`manager_id` = `user`.`id`) LIMIT 10)"
+		if got != want {
+			t.Errorf("BuildSelect() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("更新及删除", func(t *testing.T) {
+		got, _, err := d.BuildUpdate("user", map[string]interface{}{"vipLevel": 2}, map[string]interface{}{"id": orders})
+		if err != nil {
+			t.Fatalf("BuildUpdate() error = %v", err)
+		}
+		want := "UPDATE `user` SET `vip_level`=2 WHERE `id` IN (SELECT `order`.`user_id` FROM `order` WHERE (`order`.`amount` > 100))"
+		if got != want {
+			t.Errorf("BuildUpdate() = %v, want %v", got, want)
+		}
+		got, _, err = d.BuildDelete("user", map[string]interface{}{"id not in": orders})
+		if err != nil {
+			t.Fatalf("BuildDelete() error = %v", err)
+		}
+		want = "DELETE `user` FROM `user` WHERE `id` NOT IN (SELECT `order`.`user_id` FROM `order` WHERE (`order`.`amount` > 100))"
+		if got != want {
+			t.Errorf("BuildDelete() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestDialectWrapper_Lock(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		where   map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{"mysql排他锁", dialect.MYSQL, map[string]interface{}{"id": 1, "$lock": "update"}, "SELECT `id` FROM `user` WHERE (`id` = 1) FOR UPDATE", false},
+		{"mysql共享锁不等待", dialect.MYSQL, map[string]interface{}{"$lock": "share nowait"}, "SELECT `id` FROM `user` FOR SHARE NOWAIT", false},
+		{"mysql共享锁跳过已锁定的行", dialect.MYSQL, map[string]interface{}{"$lock": "share skip locked"}, "SELECT `id` FROM `user` FOR SHARE SKIP LOCKED", false},
+		{"postgres共享锁", dialect.POSTGRES, map[string]interface{}{"$lock": "share"}, `SELECT "id" FROM "user" FOR SHARE`, false},
+		{"postgres排他锁不等待", dialect.POSTGRES, map[string]interface{}{"$lock": "update nowait"}, `SELECT "id" FROM "user" FOR UPDATE NOWAIT`, false},
+		{"postgres跳过已锁定的行", dialect.POSTGRES, map[string]interface{}{"$lock": []interface{}{"update", "skip locked"}, "$limit": 1}, `SELECT "id" FROM "user" LIMIT 1 FOR UPDATE SKIP LOCKED`, false},
+		{"有连接表时只锁定本表", dialect.POSTGRES, map[string]interface{}{"$lock": true, "$join": []dialect.Join{{Table: "role", Alias: "role", Column: "id", Ref: "user.role_id"}}},
+			`SELECT "user"."id" FROM "user" LEFT JOIN "role" AS "role" ON ("role"."id" = "user"."role_id") FOR UPDATE OF "user"`, false},
+		{"sqlite没有行锁", dialect.SQLITE3, map[string]interface{}{"$lock": "update"}, "SELECT `id` FROM `user`", false},
+		{"sqlite共享锁", dialect.SQLITE3, map[string]interface{}{"$lock": "share"}, "SELECT `id` FROM `user`", false},
+		{"sqlite共享锁不等待", dialect.SQLITE3, map[string]interface{}{"$lock": "share nowait"}, "SELECT `id` FROM `user`", false},
+		{"sqlite跳过已锁定的行", dialect.SQLITE3, map[string]interface{}{"$lock": "share skip locked"}, "SELECT `id` FROM `user`", false},
+		{"sqlite无效的锁", dialect.SQLITE3, map[string]interface{}{"$lock": "update later"}, "", true},
+		{"sqlserver排他锁", dialect.SQLSERVER, map[string]interface{}{"id": 1, "$lock": "update"}, `SELECT "id" FROM "user" WITH (UPDLOCK, ROWLOCK) WHERE ("id" = 1)`, false},
+		{"sqlserver排他锁不等待", dialect.SQLSERVER, map[string]interface{}{"$lock": "update nowait"}, `SELECT "id" FROM "user" WITH (UPDLOCK, ROWLOCK, NOWAIT)`, false},
+		{"sqlserver跳过已锁定的行", dialect.SQLSERVER, map[string]interface{}{"$lock": "update skip locked"}, `SELECT "id" FROM "user" WITH (UPDLOCK, ROWLOCK, READPAST)`, false},
+		{"sqlserver共享锁", dialect.SQLSERVER, map[string]interface{}{"$lock": "share"}, `SELECT "id" FROM "user" WITH (HOLDLOCK, ROWLOCK)`, false},
+		{"sqlserver共享锁不等待", dialect.SQLSERVER, map[string]interface{}{"$lock": "share nowait"}, `SELECT "id" FROM "user" WITH (HOLDLOCK, ROWLOCK, NOWAIT)`, false},
+		{"sqlserver共享锁不能跳过已锁定的行", dialect.SQLSERVER, map[string]interface{}{"$lock": "share skip locked"}, "", true},
+		{"sqlserver有连接表时只锁定本表", dialect.SQLSERVER, map[string]interface{}{"$lock": true, "$join": []dialect.Join{{Table: "role", Alias: "role", Column: "id", Ref: "user.role_id"}}},
+			`SELECT "user"."id" FROM "user" WITH (UPDLOCK, ROWLOCK) LEFT JOIN "role" AS "role" ON ("role"."id" = "user"."role_id")`, false},
+		{"无效的锁", dialect.MYSQL, map[string]interface{}{"$lock": "update later"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			got, _, err := d.BuildSelect("user", []string{"id"}, tt.where)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildSelect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, dialect.ErrInvalidLock) {
+				t.Errorf("BuildSelect() error = %v, want ErrInvalidLock", err)
+			}
+			if strings.TrimSpace(got) != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_BuildUpsert(t *testing.T) {
+	rows := []map[string]interface{}{{"id": 1, "userName": "a"}, {"id": 2, "userName": "b"}}
+	tests := []struct {
+		dialect string
+		update  []string
+		want    string
+	}{
+		{
+			dialect.MYSQL,
+			[]string{"user_name"},
+			"INSERT INTO `user` (`id`, `user_name`) VALUES (1, 'a'), (2, 'b') ON DUPLICATE KEY UPDATE `user_name` = VALUES(`user_name`)",
+		},
+		{
+			dialect.MYSQL,
+			nil,
+			"INSERT INTO `user` (`id`, `user_name`) VALUES (1, 'a'), (2, 'b') ON DUPLICATE KEY UPDATE `id` = `id`",
+		},
+		{
+			dialect.POSTGRES,
+			[]string{"user_name"},
+			`INSERT INTO "user" ("id", "user_name") VALUES (1, 'a'), (2, 'b') ON CONFLICT ("id") DO UPDATE SET "user_name" = excluded."user_name"`,
+		},
+		{
+			dialect.SQLITE3,
+			nil,
+			"INSERT INTO `user` (`id`, `user_name`) VALUES (1, 'a'), (2, 'b') ON CONFLICT (`id`) DO NOTHING",
+		},
+		{
+			dialect.SQLSERVER,
+			[]string{"user_name"},
+			`MERGE INTO "user" AS target USING (SELECT 1 AS "id", 'a' AS "user_name" UNION ALL (SELECT 2 AS "id", 'b' AS "user_name")) AS source ` +
+				`ON (target."id" = source."id") WHEN MATCHED THEN UPDATE SET target."user_name" = source."user_name" ` +
+				`WHEN NOT MATCHED THEN INSERT ("id", "user_name") VALUES (source."id", source."user_name");`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			got, _, err := d.BuildUpsert("user", rows, []string{"id"}, tt.update)
+			if err != nil {
+				t.Fatalf("BuildUpsert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildUpsert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_BuildUpsertIncr(t *testing.T) {
+	rows := []map[string]interface{}{{"id": 1, "userName": "a"}}
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{dialect.MYSQL, "INSERT INTO `user` (`id`, `user_name`) VALUES (1, 'a') ON DUPLICATE KEY UPDATE `user_name` = VALUES(`user_name`), `version` = `version` + 1"},
+		{dialect.POSTGRES, `INSERT INTO "user" ("id", "user_name") VALUES (1, 'a') ON CONFLICT ("id") DO UPDATE SET "user_name" = excluded."user_name", "version" = "user"."version" + 1`},
+		{dialect.SQLITE3, "INSERT INTO `user` (`id`, `user_name`) VALUES (1, 'a') ON CONFLICT (`id`) DO UPDATE SET `user_name` = excluded.`user_name`, `version` = `user`.`version` + 1"},
+		{dialect.SQLSERVER, `MERGE INTO "user" AS target USING (SELECT 1 AS "id", 'a' AS "user_name") AS source ON (target."id" = source."id") ` +
+			`WHEN MATCHED THEN UPDATE SET target."user_name" = source."user_name", target."version" = target."version" + 1 ` +
+			`WHEN NOT MATCHED THEN INSERT ("id", "user_name") VALUES (source."id", source."user_name");`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			got, _, err := d.BuildUpsert("user", rows, []string{"id"}, []string{"user_name"}, "version")
+			if err != nil {
+				t.Fatalf("BuildUpsert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildUpsert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_Returning(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    string
+		ok      bool
+	}{
+		{dialect.MYSQL, "UPDATE `user` SET `age`=1", false},
+		{dialect.POSTGRES, `UPDATE "user" SET "age"=1 RETURNING *`, true},
+		{dialect.SQLITE3, "UPDATE `user` SET `age`=1 RETURNING *", true},
+		{dialect.SQLSERVER, `UPDATE "user" SET "age"=1`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			sql, _, err := d.BuildUpdate("user", map[string]interface{}{"age": 1}, map[string]interface{}{"$all": true})
+			if err != nil {
+				t.Fatalf("BuildUpdate() error = %v", err)
+			}
+			got, ok := d.Returning(sql)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Returning() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_BuildUpdate(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			"增减",
+			map[string]interface{}{"viewCount +": 1, "stock -": 2},
+			"UPDATE `user` SET `stock`=`stock` - 2,`view_count`=`view_count` + 1 WHERE (`id` = 1)",
+			false,
+		},
+		{
+			"表达式",
+			map[string]interface{}{"price": dialect.Expr{SQL: "price * ?", Args: []interface{}{2}}},
+			"UPDATE `user` SET `price`=price * 2 WHERE (`id` = 1)",
+			false,
+		},
+		{
+			"结果不能为负数",
+			map[string]interface{}{"stock -": 2, "$non_negative": "stock"},
+			"UPDATE `user` SET `stock`=`stock` - 2 WHERE ((`id` = 1) AND `stock` - 2 >= 0)",
+			false,
+		},
+		{
+			"不能为负数的字段没有增减",
+			map[string]interface{}{"stock": 2, "$non_negative": []string{"stock"}},
+			"",
+			true,
+		},
+		{
+			"未知操作符",
+			map[string]interface{}{"stock *": 2},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildUpdate("user", tt.data, map[string]interface{}{"id": 1})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, dialect.ErrInvalidUpdate) {
+				t.Errorf("BuildUpdate() error = %v, want ErrInvalidUpdate", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_BuildBulkUpdate(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "userName": "a", "age": 18},
+		{"id": 2, "userName": "b"},
+	}
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{
+			dialect.MYSQL,
+			"UPDATE `user` SET `age`=CASE `id` WHEN 1 THEN 18 ELSE `age` END," +
+				"`user_name`=CASE `id` WHEN 1 THEN 'a' WHEN 2 THEN 'b' ELSE `user_name` END WHERE (`id` IN (1, 2))",
+		},
+		{
+			dialect.POSTGRES,
+			`UPDATE "user" SET "age"=CASE "id" WHEN 1 THEN 18 ELSE "age" END,` +
+				`"user_name"=CASE "id" WHEN 1 THEN 'a' WHEN 2 THEN 'b' ELSE "user_name" END WHERE ("id" IN (1, 2))`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			got, _, err := d.BuildBulkUpdate("user", "id", rows)
+			if err != nil {
+				t.Fatalf("BuildBulkUpdate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildBulkUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	invalid := [][]map[string]interface{}{
+		nil,
+		{{"userName": "a"}},
+		{{"id": 1}},
+		{{"id": 1, "age +": 1}},
+		{{"id": 1, "age": 1}, {"id": 2, "age": 2}, {"id": 1, "age": 3}},
+	}
+	for _, dataList := range invalid {
+		if _, _, err := d.BuildBulkUpdate("user", "id", dataList); !errors.Is(err, dialect.ErrInvalidUpdate) {
+			t.Errorf("BuildBulkUpdate(%v) error = %v, want ErrInvalidUpdate", dataList, err)
+		}
+	}
+}
+
+func TestDialectWrapper_RequireWhere(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	tests := []struct {
+		name    string
+		where   map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{"没有条件", nil, "", true},
+		{"编译后为空的条件", map[string]interface{}{"$or": map[string]interface{}{}, "$limit": 10}, "", true},
+		{"所有行", map[string]interface{}{"$all": true}, "DELETE `user` FROM `user`", false},
+		{"有条件", map[string]interface{}{"id": 1}, "DELETE `user` FROM `user` WHERE (`id` = 1)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildDelete("user", tt.where)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, dialect.ErrEmptyWhere) {
+				t.Errorf("BuildDelete() error = %v, want ErrEmptyWhere", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildDelete() = %v, want %v", got, tt.want)
+			}
+			if _, _, err = d.BuildUpdate("user", map[string]interface{}{"age": 1}, tt.where); (err != nil) != tt.wantErr {
+				t.Errorf("BuildUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, _, err := d.BuildCount("user", map[string]interface{}{"stock -": 1, "$non_negative": "stock"}, map[string]interface{}{"id": 1})
+	if err != nil {
+		t.Fatalf("BuildCount() error = %v", err)
+	}
+	if want := "SELECT COUNT(*) FROM `user` WHERE ((`id` = 1) AND `stock` - 1 >= 0)"; got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+
+	// sql server使用表提示锁定
+	ss, _ := dialect.GetDialect(dialect.SQLSERVER)
+	got, _, err = ss.BuildUpdateTargets("user", "id", map[string]interface{}{"stock -": 1, "$non_negative": "stock"}, map[string]interface{}{"age": 1})
+	if err != nil {
+		t.Fatalf("BuildUpdateTargets() error = %v", err)
+	}
+	if want := `SELECT "id" FROM "user" WITH (UPDLOCK, ROWLOCK) WHERE (("age" = 1) AND "stock" - 1 >= 0)`; got != want {
+		t.Errorf("BuildUpdateTargets() = %v, want %v", got, want)
+	}
+}
+
+func TestDialectWrapper_ForeignKeySQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		onDelete string
+		want     string
+	}{
+		{"mysql级联删除", dialect.MYSQL, "cascade", "ALTER TABLE `post` ADD CONSTRAINT `FK_POST_MEMBER_ID` FOREIGN KEY (`member_id`) REFERENCES `member` (`id`) ON DELETE CASCADE"},
+		{"postgres置空", dialect.POSTGRES, "setNull", `ALTER TABLE "post" ADD CONSTRAINT "FK_POST_MEMBER_ID" FOREIGN KEY ("member_id") REFERENCES "member" ("id") ON DELETE SET NULL`},
+		{"sqlserver限制删除", dialect.SQLSERVER, "restrict", `ALTER TABLE "post" ADD CONSTRAINT "FK_POST_MEMBER_ID" FOREIGN KEY ("member_id") REFERENCES "member" ("id") ON DELETE NO ACTION`},
+		{"未设置删除处理", dialect.MYSQL, "", "ALTER TABLE `post` ADD CONSTRAINT `FK_POST_MEMBER_ID` FOREIGN KEY (`member_id`) REFERENCES `member` (`id`)"},
+		{"sqlite不支持", dialect.SQLITE3, "cascade", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			if got := d.ForeignKeySQL("post", "member_id", "member", "id", tt.onDelete); got != tt.want {
+				t.Errorf("ForeignKeySQL() = %v, want %v", got, tt.want)
+			}
+			// 不支持外键约束时不查询约束是否存在
+			if sql, _ := d.ForeignKeyExistSQL("post", "FK_POST_MEMBER_ID", "db"); (sql == "") != (tt.want == "") {
+				t.Errorf("ForeignKeyExistSQL() = %v", sql)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_IndexSQL(t *testing.T) {
+	s := schema.Parse(`{"code": "member_tag", "fields": [{"name": "memberId", "type": "int64", "index": "IDX_PAIR"}, {"name": "tagId", "type": "int64", "index": "IDX_PAIR"}, {"name": "sort", "type": "int", "index": true}]}`)
+	tests := []struct {
+		dialect string
+		want    []string
+	}{
+		{dialect.MYSQL, []string{"CREATE INDEX `IDX_PAIR` ON `member_tag` (`member_id`,`tag_id`)", "CREATE INDEX `IDX_MEMBER_TAG_SORT` ON `member_tag` (`sort`)"}},
+		{dialect.POSTGRES, []string{`CREATE INDEX IF NOT EXISTS "IDX_PAIR" ON "member_tag" ("member_id","tag_id")`, `CREATE INDEX IF NOT EXISTS "IDX_MEMBER_TAG_SORT" ON "member_tag" ("sort")`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			if got := d.IndexSQL(s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IndexSQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_Savepoint(t *testing.T) {
+	tests := []struct {
+		dialect  string
+		save     string
+		rollback string
+		release  string
+	}{
+		{dialect.MYSQL, "SAVEPOINT `sp_1`", "ROLLBACK TO SAVEPOINT `sp_1`", "RELEASE SAVEPOINT `sp_1`"},
+		{dialect.POSTGRES, `SAVEPOINT "sp_1"`, `ROLLBACK TO SAVEPOINT "sp_1"`, `RELEASE SAVEPOINT "sp_1"`},
+		{dialect.SQLSERVER, `SAVE TRANSACTION "sp_1"`, `ROLLBACK TRANSACTION "sp_1"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			if got := d.SavepointSQL("sp_1"); got != tt.save {
+				t.Errorf("SavepointSQL() = %v, want %v", got, tt.save)
+			}
+			if got := d.RollbackToSavepointSQL("sp_1"); got != tt.rollback {
+				t.Errorf("RollbackToSavepointSQL() = %v, want %v", got, tt.rollback)
+			}
+			if got := d.ReleaseSavepointSQL("sp_1"); got != tt.release {
+				t.Errorf("ReleaseSavepointSQL() = %v, want %v", got, tt.release)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_WhereOrder(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	where := map[string]interface{}{"status": 1, "age >": 18, "name like": "a%", "deptId": 2, "id": 3}
+	want := "SELECT * FROM `user` WHERE ((`age` > 18) AND (`dept_id` = 2) AND (`id` = 3) AND (`name` LIKE BINARY 'a%') AND (`status` = 1))"
+
+	// map的遍历顺序是随机的，多次生成的sql应当完全一致，以便日志比对及语句缓存
+	for i := 0; i < 20; i++ {
+		got, _, err := d.BuildSelect("user", nil, where)
+		if err != nil {
+			t.Fatalf("BuildSelect() error = %v", err)
+		}
+		if got != want {
+			t.Fatalf("BuildSelect() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDialectWrapper_LogicalKeys(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	tests := []struct {
+		name  string
+		where map[string]interface{}
+		want  string
+	}{
+		{
+			"$or作为key",
+			map[string]interface{}{"status": 1, "$or": map[string]interface{}{"id": 1, "name": "a"}},
+			"SELECT * FROM `user` WHERE (((`id` = 1) OR (`name` = 'a')) AND (`status` = 1))",
+		},
+		{
+			"$or中嵌套$and",
+			map[string]interface{}{"$or": map[string]interface{}{"id": 1, "$and": map[string]interface{}{"age >": 18, "name": "a"}}},
+			"SELECT * FROM `user` WHERE (((`age` > 18) AND (`name` = 'a')) OR (`id` = 1))",
+		},
+		{
+			"字段名加操作符的写法保持兼容",
+			map[string]interface{}{"group $or": map[string]interface{}{"id": 1, "name": "a"}},
+			"SELECT * FROM `user` WHERE ((`id` = 1) OR (`name` = 'a'))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildSelect("user", nil, tt.where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_OrderAndGroupBy(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	tests := []struct {
+		name  string
+		where map[string]interface{}
+		want  string
+	}{
+		{"单个排序", map[string]interface{}{"$order_by": "createdAt desc"}, "SELECT `dept_id` FROM `user` ORDER BY `created_at` DESC"},
+		// 每个排序字段都要保留，不能被后面的字段覆盖
+		{"多个排序", map[string]interface{}{"$order_by": []string{"createdAt desc", "id"}}, "SELECT `dept_id` FROM `user` ORDER BY `created_at` DESC, `id` ASC"},
+		{"多个分组", map[string]interface{}{"$group_by": []string{"deptId", "status"}}, "SELECT `dept_id` FROM `user` GROUP BY `dept_id`, `status`"},
+		{"json解析的分组", map[string]interface{}{"$group_by": []interface{}{"deptId", "status"}}, "SELECT `dept_id` FROM `user` GROUP BY `dept_id`, `status`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildSelect("user", []string{"deptId"}, tt.where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_LimitOffset(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	tests := []struct {
+		name  string
+		where map[string]interface{}
+		want  string
+	}{
+		{"uint", map[string]interface{}{"$limit": uint(10), "$offset": uint(5)}, "SELECT * FROM `user` LIMIT 10 OFFSET 5"},
+		{"int", map[string]interface{}{"$limit": 10, "$offset": int64(5)}, "SELECT * FROM `user` LIMIT 10 OFFSET 5"},
+		// json解析的数字为float64
+		{"json数字", map[string]interface{}{"$limit": float64(10), "$offset": float64(5)}, "SELECT * FROM `user` LIMIT 10 OFFSET 5"},
+		{"负数及小数忽略", map[string]interface{}{"$limit": -1, "$offset": 1.5}, "SELECT * FROM `user`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildSelect("user", nil, tt.where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_MultiWordOperators(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	// 操作符本身包含空格，字段名与操作符只在第一个空格处分割
+	tests := []struct {
+		name  string
+		where map[string]interface{}
+		want  string
+	}{
+		{"not in", map[string]interface{}{"id not in": []interface{}{1, 2}}, "SELECT * FROM `user` WHERE (`id` NOT IN (1, 2))"},
+		{"not like", map[string]interface{}{"name not like": "a%"}, "SELECT * FROM `user` WHERE (`name` NOT LIKE BINARY 'a%')"},
+		{"not between", map[string]interface{}{"age not between": []interface{}{1, 9}}, "SELECT * FROM `user` WHERE (`age` NOT BETWEEN 1 AND 9)"},
+		{"is not null", map[string]interface{}{"deletedAt is not null": true}, "SELECT * FROM `user` WHERE (`deleted_at` IS NOT NULL)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := d.BuildSelect("user", nil, tt.where)
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_Quoting(t *testing.T) {
+	// mysql方言依赖goqu的mysql方言注册，未注册时goqu退回默认方言，标识符会使用双引号
+	tests := []struct {
+		dialect string
+		want    string
+	}{
+		{dialect.MYSQL, "SELECT `user_name` FROM `user` WHERE (`user_name` = 'it\\'s')"},
+		{dialect.POSTGRES, `SELECT "user_name" FROM "user" WHERE ("user_name" = 'it''s')`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			got, _, err := d.BuildSelect("user", []string{"userName"}, map[string]interface{}{"userName": "it's"})
+			if err != nil {
+				t.Fatalf("BuildSelect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSelect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialectWrapper_InvalidCondition(t *testing.T) {
+	d, _ := dialect.GetDialect(dialect.MYSQL)
+	// 错误的条件不能被忽略，否则更新、删除的范围会扩大
+	invalid := []map[string]interface{}{
+		{"id": 1, "tags contains": make(chan int)},
+		{"id": 1, "age between": []interface{}{1}},
+		{"id": 1, "age not between": 1},
+		{"id": 1, "$or": "name = 'a'"},
+		{"id": 1, "$and": []interface{}{"a"}},
+		{"id": 1, "group $or": "name = 'a'"},
+		{"id": 1, "$or": map[string]interface{}{"age between": []interface{}{1, 2, 3}}},
+		{"id": 1, "roleId in": dialect.SubQuery{Table: "role", Field: "id", Where: map[string]interface{}{"$and": 1}}},
+	}
+	for _, where := range invalid {
+		if _, _, err := d.BuildDelete("user", where); !errors.Is(err, dialect.ErrInvalidCondition) {
+			t.Errorf("BuildDelete(%v) error = %v, want ErrInvalidCondition", where, err)
+		}
+		if _, _, err := d.BuildUpdate("user", map[string]interface{}{"age": 1}, where); !errors.Is(err, dialect.ErrInvalidCondition) {
+			t.Errorf("BuildUpdate(%v) error = %v, want ErrInvalidCondition", where, err)
+		}
+		if _, _, err := d.BuildCount("user", nil, where); !errors.Is(err, dialect.ErrInvalidCondition) {
+			t.Errorf("BuildCount(%v) error = %v, want ErrInvalidCondition", where, err)
+		}
+		if _, _, err := d.BuildSelect("user", nil, where); !errors.Is(err, dialect.ErrInvalidCondition) {
+			t.Errorf("BuildSelect(%v) error = %v, want ErrInvalidCondition", where, err)
+		}
+	}
+
+	_, _, err := d.BuildSelect("user", nil, map[string]interface{}{"$group_by": "age", "$having": "count(*) > 1"})
+	if !errors.Is(err, dialect.ErrInvalidCondition) {
+		t.Errorf("BuildSelect() error = %v, want ErrInvalidCondition", err)
+	}
+}
+
+func TestDialectWrapper_IndexStatements(t *testing.T) {
+	s := schema.Parse(`{"code": "article", "fields": [{"name": "id", "type": "ID"}, {"name": "title", "type": "string", "fulltext": "FTS_TITLE", "index": true}, {"name": "content", "type": "text", "fulltext": true}, {"name": "code", "type": "string", "unique": true}]}`)
+	tests := []struct {
+		dialect   string
+		fulltext  []string // 各全文索引的名称
+		existArgs []interface{}
+	}{
+		{dialect.MYSQL, []string{"FTS_TITLE", "FTS_ARTICLE_CONTENT"}, []interface{}{"article", "FTS_TITLE", "db"}},
+		{dialect.POSTGRES, []string{"FTS_TITLE", "FTS_ARTICLE_CONTENT"}, []interface{}{"article", "FTS_TITLE"}},
+		// sqlite的全文索引为一个fts5虚拟表
+		{dialect.SQLITE3, []string{"article_fts"}, []interface{}{"FTS_TITLE"}},
+		{dialect.SQLSERVER, nil, []interface{}{"article", "FTS_TITLE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, _ := dialect.GetDialect(tt.dialect)
+			var names, sqls []string
+			for _, statement := range d.FulltextIndexStatements(s) {
+				names = append(names, statement.Name)
+				sqls = append(sqls, statement.SQL...)
+			}
+			if !reflect.DeepEqual(names, tt.fulltext) {
+				t.Errorf("FulltextIndexStatements() names = %v, want %v", names, tt.fulltext)
+			}
+			// 分组后的语句与FulltextIndexSQL一致
+			if !reflect.DeepEqual(sqls, d.FulltextIndexSQL(s)) {
+				t.Errorf("FulltextIndexStatements() = %v, want %v", sqls, d.FulltextIndexSQL(s))
+			}
+			if statements := d.IndexStatements(s); len(statements) != 1 || statements[0].Name != "IDX_ARTICLE_TITLE" {
+				t.Errorf("IndexStatements() = %v", statements)
+			}
+			if statements := d.UniqueIndexStatements(s); len(statements) != 1 || statements[0].Name != "UNI_ARTICLE_CODE" ||
+				!strings.HasPrefix(statements[0].SQL[0], "CREATE UNIQUE INDEX ") {
+				t.Errorf("UniqueIndexStatements() = %v", statements)
+			}
+			if _, args := d.IndexExistSQL("article", "FTS_TITLE", "db"); !reflect.DeepEqual(args, tt.existArgs) {
+				t.Errorf("IndexExistSQL() args = %v, want %v", args, tt.existArgs)
+			}
+		})
+	}
+}
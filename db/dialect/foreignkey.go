@@ -0,0 +1,52 @@
+package dialect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// ForeignKeySQL 在关联模型的表上建立外键约束，table.column引用refTable.refColumn，onDelete为关系中的删除处理
+// sqlite不支持为已有的表添加外键约束，返回空字符串
+func (m *DialectWrapper) ForeignKeySQL(table, column, refTable, refColumn, onDelete string) string {
+	if m.Name == SQLITE3 {
+		return ""
+	}
+	name := ForeignKeyName(table, column)
+	sql := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		m.quote(table), m.quote(name), m.quote(column), m.quote(refTable), m.quote(refColumn))
+	switch onDelete {
+	case schema.ON_DELETE_CASCADE:
+		sql += " ON DELETE CASCADE"
+	case schema.ON_DELETE_SET_NULL:
+		sql += " ON DELETE SET NULL"
+	case schema.ON_DELETE_RESTRICT:
+		if m.Name == SQLSERVER {
+			// sql server没有RESTRICT，NO ACTION同样拒绝删除有关联数据的行
+			sql += " ON DELETE NO ACTION"
+		} else {
+			sql += " ON DELETE RESTRICT"
+		}
+	}
+	return sql
+}
+
+// ForeignKeyName 外键约束的名称，如：FK_POST_MEMBER_ID
+func ForeignKeyName(table, column string) string {
+	return strings.ToUpper(fmt.Sprintf("FK_%s_%s", table, column))
+}
+
+// ForeignKeyExistSQL 返回查询外键约束是否存在的sql语句，sql查询应只有一个字段，约束名称；sqlite不支持外键约束，返回空字符串
+func (m *DialectWrapper) ForeignKeyExistSQL(tableName, name, dbName string) (string, []interface{}) {
+	args := []interface{}{tableName, name, dbName}
+	switch m.Name {
+	case SQLITE3:
+		return "", nil
+	case POSTGRES:
+		return "SELECT constraint_name FROM information_schema.table_constraints WHERE constraint_type = 'FOREIGN KEY' AND table_name = ? AND constraint_name = ? AND table_catalog = ? AND table_schema = current_schema()", args
+	case SQLSERVER:
+		return "SELECT CONSTRAINT_NAME FROM information_schema.table_constraints WHERE CONSTRAINT_TYPE = 'FOREIGN KEY' AND TABLE_NAME = ? AND CONSTRAINT_NAME = ? AND TABLE_CATALOG = ?", args
+	}
+	return "SELECT CONSTRAINT_NAME FROM information_schema.table_constraints WHERE CONSTRAINT_TYPE = 'FOREIGN KEY' AND TABLE_NAME = ? AND CONSTRAINT_NAME = ? AND TABLE_SCHEMA = ?", args
+}
@@ -0,0 +1,196 @@
+package dialect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/goqu/exp"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// fulltextMatch 条件中的全文检索，用于按相关度排序
+type fulltextMatch struct {
+	name  string
+	value interface{}
+}
+
+// fulltextTable sqlite全文检索使用的fts5虚拟表名称
+func fulltextTable(tableName string) string {
+	return tableName + "_fts"
+}
+
+// FulltextIndexSQL 返回建立全文索引的sql语句，在建表之后执行
+// mysql: FULLTEXT索引，可以指定分词器，如：ngram
+// postgres: 基于to_tsvector表达式的GIN索引
+// sqlite: 以模型表为外部内容的fts5虚拟表，并通过触发器同步数据
+// sql server的全文索引需要全文目录及唯一索引，需要自行建立
+func (m *DialectWrapper) FulltextIndexSQL(s *schema.Schema) []string {
+	return flattenIndexes(m.FulltextIndexStatements(s))
+}
+
+// FulltextIndexStatements 按索引分组返回FulltextIndexSQL的语句，
+// sqlite的所有全文索引字段在同一个fts5虚拟表中，索引名称为虚拟表的名称
+func (m *DialectWrapper) FulltextIndexStatements(s *schema.Schema) []IndexStatement {
+	indexes := s.FulltextIndexes()
+	if len(indexes) == 0 || m.Name == SQLSERVER {
+		return nil
+	}
+	var statements []IndexStatement
+	switch m.Name {
+	case POSTGRES:
+		for _, index := range indexes {
+			statements = append(statements, IndexStatement{Name: index.Name, SQL: []string{
+				"CREATE INDEX IF NOT EXISTS " + m.quote(index.Name) + " ON " + m.quote(s.TableName) +
+					" USING GIN (" + m.tsvector(index.Columns) + ")",
+			}})
+		}
+	case SQLITE3:
+		// sqlite的fts5虚拟表包含所有全文索引字段
+		var columns, quoted, newValues, oldValues []string
+		for _, index := range indexes {
+			for _, column := range index.Columns {
+				columns = append(columns, column)
+				quoted = append(quoted, m.quote(column))
+				newValues = append(newValues, "new."+m.quote(column))
+				oldValues = append(oldValues, "old."+m.quote(column))
+			}
+		}
+		table, fts := m.quote(s.TableName), m.quote(fulltextTable(s.TableName))
+		ftsColumns := strings.Join(quoted, ", ")
+		insert := "INSERT INTO " + fts + " (rowid, " + ftsColumns + ") VALUES (new.rowid, " + strings.Join(newValues, ", ") + ");"
+		remove := "INSERT INTO " + fts + " (" + fts + ", rowid, " + ftsColumns + ") VALUES ('delete', old.rowid, " + strings.Join(oldValues, ", ") + ");"
+		trigger := func(event string) string {
+			return "CREATE TRIGGER IF NOT EXISTS " + m.quote(fulltextTable(s.TableName)+"_"+strings.ToLower(event)) +
+				" AFTER " + event + " ON " + table + " BEGIN "
+		}
+		statements = append(statements, IndexStatement{Name: fulltextTable(s.TableName), SQL: []string{
+			"CREATE VIRTUAL TABLE IF NOT EXISTS " + fts + " USING fts5(" + strings.Join(columns, ", ") + ", content='" + s.TableName + "')",
+			trigger("INSERT") + insert + " END",
+			trigger("DELETE") + remove + " END",
+			trigger("UPDATE") + remove + " " + insert + " END",
+		}})
+	default:
+		for _, index := range indexes {
+			var quoted []string
+			for _, column := range index.Columns {
+				quoted = append(quoted, m.quote(column))
+			}
+			sql := "CREATE FULLTEXT INDEX " + m.quote(index.Name) + " ON " + m.quote(s.TableName) + " (" + strings.Join(quoted, ", ") + ")"
+			if index.Parser != "" {
+				sql += " WITH PARSER " + index.Parser
+			}
+			statements = append(statements, IndexStatement{Name: index.Name, SQL: []string{sql}})
+		}
+	}
+	return statements
+}
+
+// tsvector 生成postgres的文档向量表达式，多个列拼接后分词，建立索引与查询时的表达式必须一致
+func (m *DialectWrapper) tsvector(columns []string) string {
+	var parts []string
+	for _, column := range columns {
+		parts = append(parts, "coalesce("+m.quote(column)+", '')")
+	}
+	return "to_tsvector('simple', " + strings.Join(parts, " || ' ' || ") + ")"
+}
+
+// matchColumns 解析match条件中的字段，多个字段使用逗号分隔，如：title,content match，
+// 字段应与全文索引中的字段及顺序一致，多个字段应属于同一个表
+func (sc *scope) matchColumns(name string) (table string, columns []string) {
+	for _, field := range strings.Split(name, ",") {
+		t, column, _ := sc.resolve(strings.TrimSpace(field))
+		table = t
+		columns = append(columns, column)
+	}
+	return table, columns
+}
+
+// tableName 返回表别名对应的表名
+func (sc *scope) tableName(alias string) string {
+	for _, j := range sc.joins {
+		if j.Alias == alias {
+			return j.Table
+		}
+	}
+	return sc.table
+}
+
+// ftsQuery 将检索内容转为fts5的查询，各个词作为短语，避免特殊字符被解析为查询语法
+func ftsQuery(columns []string, v interface{}) string {
+	var terms []string
+	for _, term := range strings.Fields(fmt.Sprint(v)) {
+		terms = append(terms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	return "{" + strings.Join(columns, " ") + "} : " + strings.Join(terms, " ")
+}
+
+// match 生成全文检索条件
+// mysql: MATCH (col, ...) AGAINST (? IN NATURAL LANGUAGE MODE)
+// postgres: to_tsvector(...) @@ plainto_tsquery('simple', ?)
+// sqlite: rowid IN (SELECT rowid FROM table_fts WHERE table_fts MATCH ?)
+// sql server: FREETEXT((col, ...), ?)，不支持按相关度排序
+func (sc *scope) match(name string, v interface{}) goqu.Expression {
+	table, columns := sc.matchColumns(name)
+	if sc.dialect == SQLSERVER {
+		return goqu.L("FREETEXT((?), ?)", sc.identList(table, columns), v)
+	}
+	sc.matches = append(sc.matches, fulltextMatch{name: name, value: v})
+	switch sc.dialect {
+	case POSTGRES:
+		return goqu.L("? @@ plainto_tsquery('simple', ?)", sc.tsvector(table, columns), v)
+	case SQLITE3:
+		fts := fulltextTable(sc.tableName(table))
+		return goqu.L("? IN (SELECT rowid FROM ? WHERE ? MATCH ?)", sc.rowid(table), goqu.T(fts), goqu.T(fts), ftsQuery(columns, v))
+	}
+	return goqu.L("MATCH (?) AGAINST (? IN NATURAL LANGUAGE MODE)", sc.identList(table, columns), v)
+}
+
+// relevance 返回全文检索的相关度，值越大越相关
+// sqlite的bm25值越小越相关，取负值
+func (sc *scope) relevance(fm fulltextMatch) exp.Orderable {
+	table, columns := sc.matchColumns(fm.name)
+	switch sc.dialect {
+	case POSTGRES:
+		return goqu.L("ts_rank(?, plainto_tsquery('simple', ?))", sc.tsvector(table, columns), fm.value)
+	case SQLITE3:
+		fts := fulltextTable(sc.tableName(table))
+		return goqu.L("(SELECT -rank FROM ? WHERE ? MATCH ? AND rowid = ?)", goqu.T(fts), goqu.T(fts), ftsQuery(columns, fm.value), sc.rowid(table))
+	}
+	return goqu.L("MATCH (?) AGAINST (? IN NATURAL LANGUAGE MODE)", sc.identList(table, columns), fm.value)
+}
+
+// tsvector 查询中使用的文档向量表达式，与建立索引时的表达式一致
+func (sc *scope) tsvector(table string, columns []string) exp.LiteralExpression {
+	var parts []string
+	var args []interface{}
+	for _, column := range columns {
+		parts = append(parts, "coalesce(?, '')")
+		args = append(args, sc.ident(table, column))
+	}
+	return goqu.L("to_tsvector('simple', "+strings.Join(parts, " || ' ' || ")+")", args...)
+}
+
+// rowid 返回sqlite表的rowid，始终带上表名，避免在子查询中指向fts5虚拟表的rowid
+func (sc *scope) rowid(table string) exp.IdentifierExpression {
+	if table == "" {
+		table = sc.name()
+	}
+	return goqu.T(table).Col("rowid")
+}
+
+func (sc *scope) identList(table string, columns []string) exp.ColumnListExpression {
+	var cols []interface{}
+	for _, column := range columns {
+		cols = append(cols, sc.ident(table, column))
+	}
+	return exp.NewColumnListExpression(cols...)
+}
+
+// orderByRelevance 按条件中全文检索的相关度倒序
+func (sc *scope) orderByRelevance(ds *goqu.SelectDataset) *goqu.SelectDataset {
+	for _, fm := range sc.matches {
+		ds = ds.OrderAppend(sc.relevance(fm).Desc())
+	}
+	return ds
+}
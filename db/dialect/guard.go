@@ -0,0 +1,79 @@
+package dialect
+
+import (
+	"errors"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/goqu/exp"
+)
+
+var (
+	ErrEmptyWhere error = errors.New("update or delete without where condition")
+)
+
+// requireWhere 更新、删除的条件编译后不能为空，避免误操作全表，条件中$all为true时允许操作所有行
+func requireWhere(where map[string]interface{}, whereExList []goqu.Expression) error {
+	if all, _ := where[OP_ALL].(bool); all {
+		return nil
+	}
+	for _, ex := range whereExList {
+		if !isEmptyExpression(ex) {
+			return nil
+		}
+	}
+	return ErrEmptyWhere
+}
+
+// isEmptyExpression 判断条件是否为空，空的$and/$or等表达式列表不会生成sql
+func isEmptyExpression(ex goqu.Expression) bool {
+	list, ok := ex.(exp.ExpressionList)
+	if !ok {
+		return ex == nil
+	}
+	for _, e := range list.Expressions() {
+		if !isEmptyExpression(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildCount 统计更新或删除将要影响的行数，条件同BuildUpdate/BuildDelete，updateData中的$non_negative同样作为条件
+func (m *DialectWrapper) BuildCount(tableName string, updateData, where map[string]interface{}) (string, []interface{}, error) {
+	whereExList, err := guardedWhere(m.newScope(tableName, nil), updateData, where)
+	if err != nil {
+		return "", nil, err
+	}
+	return m.Dialect.From(tableName).Select(goqu.COUNT(goqu.Star())).Where(whereExList...).ToSQL()
+}
+
+// BuildUpdateTargets 查询并锁定更新将要影响的行的主键，条件同BuildUpdate，updateData中的$non_negative同样作为条件
+// 不支持RETURNING的数据库先确定更新的行，按主键更新后重新查询
+func (m *DialectWrapper) BuildUpdateTargets(tableName, pkColumn string, updateData, where map[string]interface{}) (string, []interface{}, error) {
+	sc := m.newScope(tableName, nil)
+	whereExList, err := guardedWhere(sc, updateData, where)
+	if err != nil {
+		return "", nil, err
+	}
+	ds, err := sc.lock(m.Dialect.From(tableName).Select(goqu.C(pkColumn)).Where(whereExList...), LOCK_UPDATE)
+	if err != nil {
+		return "", nil, err
+	}
+	return ds.ToSQL()
+}
+
+// guardedWhere 返回更新或删除的条件，包括updateData中$non_negative对应的条件
+func guardedWhere(sc *scope, updateData, where map[string]interface{}) ([]goqu.Expression, error) {
+	whereExList, err := whereExpression(where, sc)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireWhere(where, whereExList); err != nil {
+		return nil, err
+	}
+	_, guards, err := updateRecord(updateData)
+	if err != nil {
+		return nil, err
+	}
+	return append(whereExList, guards...), nil
+}
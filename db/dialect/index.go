@@ -0,0 +1,30 @@
+package dialect
+
+// IndexStatement 建立一个索引的语句，索引不存在时依次执行SQL
+type IndexStatement struct {
+	Name string // 索引名称，用于检查索引是否存在
+	SQL  []string
+}
+
+// IndexExistSQL 返回查询索引是否存在的sql语句，sql查询应只有一个字段，索引名称
+// sqlite同时查询表，fts5虚拟表作为全文索引
+func (m *DialectWrapper) IndexExistSQL(tableName, name, dbName string) (string, []interface{}) {
+	switch m.Name {
+	case POSTGRES:
+		return "SELECT indexname FROM pg_indexes WHERE tablename = ? AND indexname = ? AND schemaname = current_schema()", []interface{}{tableName, name}
+	case SQLITE3:
+		return "SELECT name FROM sqlite_master WHERE type IN ('index', 'table') AND name = ?", []interface{}{name}
+	case SQLSERVER:
+		return "SELECT name FROM sys.indexes WHERE object_id = OBJECT_ID(?) AND name = ?", []interface{}{tableName, name}
+	}
+	return "SELECT INDEX_NAME FROM information_schema.statistics WHERE TABLE_NAME = ? AND INDEX_NAME = ? AND TABLE_SCHEMA = ?", []interface{}{tableName, name, dbName}
+}
+
+// flattenIndexes 按顺序返回所有索引的语句
+func flattenIndexes(statements []IndexStatement) []string {
+	var sqls []string
+	for _, statement := range statements {
+		sqls = append(sqls, statement.SQL...)
+	}
+	return sqls
+}
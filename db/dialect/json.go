@@ -0,0 +1,136 @@
+package dialect
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/goqu/exp"
+)
+
+// json路径中不需要加引号的键
+var plainJSONKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPath 生成mysql、sqlite的json路径，如：$.address.city，数字为数组下标，如：$.tags[0]
+func jsonPath(path []string) string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, p := range path {
+		if _, err := strconv.Atoi(p); err == nil {
+			sb.WriteString("[" + p + "]")
+		} else if plainJSONKeyRegexp.MatchString(p) {
+			sb.WriteString("." + p)
+		} else {
+			sb.WriteString(`."` + strings.ReplaceAll(p, `"`, `\"`) + `"`)
+		}
+	}
+	return sb.String()
+}
+
+// pgJSONPath 生成postgres的json路径数组，如：{address,city}
+func pgJSONPath(path []string) string {
+	elems := make([]string, len(path))
+	for i, p := range path {
+		if plainJSONKeyRegexp.MatchString(p) {
+			elems[i] = p
+		} else if _, err := strconv.Atoi(p); err == nil {
+			elems[i] = p
+		} else {
+			elems[i] = `"` + strings.ReplaceAll(p, `"`, `\"`) + `"`
+		}
+	}
+	return "{" + strings.Join(elems, ",") + "}"
+}
+
+// pgCast postgres的->>取出的值为文本，与数字、布尔值比较时需要转换类型
+func pgCast(v interface{}) string {
+	if s, ok := toSlice(v); ok {
+		if len(s) == 0 {
+			return ""
+		}
+		v = s[0]
+	}
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return "::numeric"
+	case bool:
+		return "::boolean"
+	}
+	return ""
+}
+
+// jsonValue 返回json字段中路径对应的值，用于条件及排序，字符串值不带引号
+// mysql: JSON_UNQUOTE(JSON_EXTRACT(col, '$.a.b'))
+// postgres: (col #>> '{a,b}')
+// sqlite: json_extract(col, '$.a.b')
+func (sc *scope) jsonValue(col exp.IdentifierExpression, path []string, v interface{}) columnExpression {
+	switch sc.dialect {
+	case POSTGRES:
+		return goqu.L("(? #>> ?)"+pgCast(v), col, pgJSONPath(path))
+	case SQLITE3:
+		return goqu.L("json_extract(?, ?)", col, jsonPath(path))
+	case SQLSERVER:
+		return goqu.L("JSON_VALUE(?, ?)", col, jsonPath(path))
+	}
+	return goqu.L("JSON_UNQUOTE(JSON_EXTRACT(?, ?))", col, jsonPath(path))
+}
+
+// jsonProject 返回json字段中路径对应的json值，用于查询字段
+func (sc *scope) jsonProject(col exp.IdentifierExpression, path []string) exp.LiteralExpression {
+	switch sc.dialect {
+	case POSTGRES:
+		return goqu.L("(? #> ?)", col, pgJSONPath(path))
+	case SQLITE3:
+		return goqu.L("json_extract(?, ?)", col, jsonPath(path))
+	case SQLSERVER:
+		// JSON_QUERY只能取出对象或数组，JSON_VALUE只能取出标量
+		return goqu.L("COALESCE(JSON_QUERY(?, ?), JSON_VALUE(?, ?))", col, jsonPath(path), col, jsonPath(path))
+	}
+	return goqu.L("JSON_EXTRACT(?, ?)", col, jsonPath(path))
+}
+
+// contains 生成json包含条件，字段可以带json路径，如：tags contains "go"，profile.tags contains ["a", "b"]
+// mysql: JSON_CONTAINS(col, '"go"'[, '$.path'])
+// postgres: col[ #> '{path}'] @> '"go"'
+// sqlite、sql server没有包含运算，使用json_each、OPENJSON逐个判断
+func (sc *scope) contains(name string, v interface{}) (goqu.Expression, error) {
+	table, column, path := sc.resolve(name)
+	col := sc.ident(table, column)
+
+	switch sc.dialect {
+	case POSTGRES:
+		candidate, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) > 0 {
+			return goqu.L("(? #> ?) @> ?::jsonb", col, pgJSONPath(path), string(candidate)), nil
+		}
+		return goqu.L("? @> ?::jsonb", col, string(candidate)), nil
+	case SQLITE3, SQLSERVER:
+		each := "json_each"
+		if sc.dialect == SQLSERVER {
+			each = "OPENJSON"
+		}
+		values, ok := toSlice(v)
+		if !ok {
+			values = []interface{}{v}
+		}
+		var exList []goqu.Expression
+		for _, value := range values {
+			exList = append(exList, goqu.L("EXISTS (SELECT 1 FROM "+each+"(?, ?) WHERE value = ?)", col, jsonPath(path), value))
+		}
+		return goqu.And(exList...), nil
+	}
+
+	candidate, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0 {
+		return goqu.L("JSON_CONTAINS(?, ?, ?)", col, string(candidate), jsonPath(path)), nil
+	}
+	return goqu.L("JSON_CONTAINS(?, ?)", col, string(candidate)), nil
+}
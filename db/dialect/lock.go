@@ -0,0 +1,100 @@
+package dialect
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/goqu/exp"
+)
+
+var (
+	ErrInvalidLock error = errors.New("invalid lock")
+)
+
+// 行锁的类型及等待方式
+const (
+	LOCK_UPDATE      = "update"
+	LOCK_SHARE       = "share"
+	LOCK_NOWAIT      = "nowait"
+	LOCK_SKIP_LOCKED = "skip locked"
+)
+
+// parseLock 解析$lock的值，格式为 类型[ 等待方式]，如：update、share nowait、update skip locked，
+// 也可以是数组，如：["update", "nowait"]
+func parseLock(v interface{}) (exp.LockStrength, exp.WaitOption, error) {
+	var lock string
+	if b, ok := v.(bool); ok && b {
+		lock = LOCK_UPDATE
+	} else {
+		lock = strings.ToLower(strings.Join(toStrings(v), " "))
+	}
+
+	strength, option, _ := strings.Cut(strings.Join(strings.Fields(lock), " "), " ")
+	var lockStrength exp.LockStrength
+	switch strength {
+	case LOCK_UPDATE:
+		lockStrength = exp.ForUpdate
+	case LOCK_SHARE:
+		lockStrength = exp.ForShare
+	default:
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidLock, v)
+	}
+	switch option {
+	case "":
+		return lockStrength, exp.Wait, nil
+	case LOCK_NOWAIT:
+		return lockStrength, exp.NoWait, nil
+	case LOCK_SKIP_LOCKED:
+		return lockStrength, exp.SkipLocked, nil
+	}
+	return 0, 0, fmt.Errorf("%w: %v", ErrInvalidLock, v)
+}
+
+// lock 为查询添加行锁，有连接表时只锁定本表的行
+// sqlite没有行锁，写事务会锁定整个数据库，任何锁定方式都不生成锁定语句；
+// sql server没有FOR UPDATE子句，使用表提示锁定本表的行
+func (sc *scope) lock(ds *goqu.SelectDataset, v interface{}) (*goqu.SelectDataset, error) {
+	strength, option, err := parseLock(v)
+	if err != nil {
+		return nil, err
+	}
+	switch sc.dialect {
+	case SQLITE3:
+		return ds, nil
+	case SQLSERVER:
+		hints, err := tableHints(strength, option)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", err, v)
+		}
+		return ds.From(goqu.L("? WITH ("+hints+")", goqu.T(sc.table))), nil
+	}
+	var of []exp.IdentifierExpression
+	if len(sc.joins) > 0 {
+		of = append(of, goqu.T(sc.name()))
+	}
+	if strength == exp.ForShare {
+		return ds.ForShare(option, of...), nil
+	}
+	return ds.ForUpdate(option, of...), nil
+}
+
+// tableHints 返回sql server的锁定表提示，排他锁为UPDLOCK，共享锁为HOLDLOCK，
+// 不等待为NOWAIT，跳过已锁定的行为READPAST；READPAST不能与HOLDLOCK同时使用
+func tableHints(strength exp.LockStrength, option exp.WaitOption) (string, error) {
+	hints := "UPDLOCK, ROWLOCK"
+	if strength == exp.ForShare {
+		hints = "HOLDLOCK, ROWLOCK"
+	}
+	switch option {
+	case exp.NoWait:
+		hints += ", NOWAIT"
+	case exp.SkipLocked:
+		if strength == exp.ForShare {
+			return "", ErrInvalidLock
+		}
+		hints += ", READPAST"
+	}
+	return hints, nil
+}
@@ -3,13 +3,15 @@ package mysql
 import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/yaochi-tech/goqu"
+	_ "github.com/yaochi-tech/goqu/dialect/mysql"
 	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
 )
 
 var _ dialect.Dialect = (*dialect.DialectWrapper)(nil)
 
 func init() {
-	dialect.RegisterDialect("mysql", &dialect.DialectWrapper{
-		Dialect: goqu.Dialect("mysql"),
+	dialect.RegisterDialect(dialect.MYSQL, &dialect.DialectWrapper{
+		Name:    dialect.MYSQL,
+		Dialect: goqu.Dialect(dialect.MYSQL),
 	})
 }
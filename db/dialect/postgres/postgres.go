@@ -0,0 +1,17 @@
+// Package postgres 注册postgres方言，数据库驱动需要自行引入，如：_ "github.com/lib/pq"
+package postgres
+
+import (
+	"github.com/yaochi-tech/goqu"
+	_ "github.com/yaochi-tech/goqu/dialect/postgres"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+)
+
+var _ dialect.Dialect = (*dialect.DialectWrapper)(nil)
+
+func init() {
+	dialect.RegisterDialect(dialect.POSTGRES, &dialect.DialectWrapper{
+		Name:    dialect.POSTGRES,
+		Dialect: goqu.Dialect(dialect.POSTGRES),
+	})
+}
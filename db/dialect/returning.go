@@ -0,0 +1,11 @@
+package dialect
+
+// Returning 为insert/update语句追加RETURNING *，返回写入后的完整行，postgres及sqlite（3.35及以上）支持
+// 不支持的方言返回false，由调用者按主键重新查询
+func (m *DialectWrapper) Returning(sql string) (string, bool) {
+	switch m.Name {
+	case POSTGRES, SQLITE3:
+		return sql + " RETURNING *", true
+	}
+	return sql, false
+}
@@ -0,0 +1,25 @@
+package dialect
+
+// SavepointSQL 建立保存点的语句，用于嵌套事务，sql server使用SAVE TRANSACTION
+func (m *DialectWrapper) SavepointSQL(name string) string {
+	if m.Name == SQLSERVER {
+		return "SAVE TRANSACTION " + m.quote(name)
+	}
+	return "SAVEPOINT " + m.quote(name)
+}
+
+// RollbackToSavepointSQL 回滚到保存点的语句
+func (m *DialectWrapper) RollbackToSavepointSQL(name string) string {
+	if m.Name == SQLSERVER {
+		return "ROLLBACK TRANSACTION " + m.quote(name)
+	}
+	return "ROLLBACK TO SAVEPOINT " + m.quote(name)
+}
+
+// ReleaseSavepointSQL 释放保存点的语句，sql server没有释放保存点的语句，返回空字符串
+func (m *DialectWrapper) ReleaseSavepointSQL(name string) string {
+	if m.Name == SQLSERVER {
+		return ""
+	}
+	return "RELEASE SAVEPOINT " + m.quote(name)
+}
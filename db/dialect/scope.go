@@ -0,0 +1,164 @@
+package dialect
+
+import (
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/goqu/exp"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+// Join 关联查询时连接的表，由引擎根据模型关系生成，通过条件中的$join传入BuildSelect
+// 连接条件为 Alias.Column = Ref，Ref为已连接的表的字段，格式为 表名或别名.字段
+type Join struct {
+	Table  string
+	Alias  string
+	Column string
+	Ref    string
+	ToMany bool // 一对多连接，查询结果需要去重
+}
+
+// columnExpression 条件、排序中可以使用的字段表达式，为普通字段或json路径取值
+type columnExpression interface {
+	exp.Expression
+	exp.Aliaseable
+	exp.Comparable
+	exp.Inable
+	exp.Isable
+	exp.Likeable
+	exp.Rangeable
+	exp.Orderable
+}
+
+// scope 生成语句时的方言及表信息，有连接表时本表的字段需要带上表名，避免字段名冲突
+type scope struct {
+	dialect  string
+	builder  goqu.DialectWrapper
+	table    string
+	alias    string // 子查询中表的别名，与外层查询为同一个表时使用
+	qualify  bool   // 字段是否总是带上表名，子查询中使用
+	depth    int    // 子查询的嵌套层数
+	joins    []Join
+	matches  []fulltextMatch // 条件中的全文检索
+	selected []string        // 查询的字段，为空时查询本表的所有字段
+}
+
+func (m *DialectWrapper) newScope(tableName string, where map[string]interface{}) *scope {
+	sc := &scope{dialect: m.Name, builder: m.Dialect, table: tableName}
+	if joins, ok := where[OP_JOIN].([]Join); ok {
+		sc.joins = joins
+	}
+	return sc
+}
+
+// name 返回本表在语句中的名称，有别名时为别名
+func (sc *scope) name() string {
+	if sc.alias != "" {
+		return sc.alias
+	}
+	return sc.table
+}
+
+// isAlias 判断是否为连接表的别名
+func (sc *scope) isAlias(name string) bool {
+	for _, j := range sc.joins {
+		if j.Alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 解析条件中的字段名，返回表别名、蛇形命名的列名以及json路径
+// 如：role.name 中role为连接表别名；profile.address.city 中profile为json字段，address.city为json路径
+// json路径区分大小写，不转换为蛇形命名
+func (sc *scope) resolve(name string) (table, column string, path []string) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 1 && sc.isAlias(util.ToSnake(parts[0])) {
+		table, parts = util.ToSnake(parts[0]), parts[1:]
+	} else if len(sc.joins) > 0 || sc.qualify {
+		table = sc.name()
+	}
+	return table, util.ToSnake(parts[0]), parts[1:]
+}
+
+// ident 返回列的标识
+func (sc *scope) ident(table, column string) exp.IdentifierExpression {
+	if table != "" {
+		return goqu.T(table).Col(column)
+	}
+	return goqu.C(column)
+}
+
+// column 返回字段对应的表达式，v为条件的值，用于json路径取值时确定比较的类型
+func (sc *scope) column(name string, v interface{}) columnExpression {
+	table, column, path := sc.resolve(name)
+	if len(path) == 0 {
+		return sc.ident(table, column)
+	}
+	return sc.jsonValue(sc.ident(table, column), path, v)
+}
+
+// distinct 是否有一对多的连接
+func (sc *scope) distinct() bool {
+	for _, j := range sc.joins {
+		if j.ToMany {
+			return true
+		}
+	}
+	return false
+}
+
+// orderable 判断字段能否用于排序，有一对多连接时查询会去重，postgres、mysql要求排序字段必须在查询字段中
+// 未指定查询字段时只能按本表的字段排序，一对多关联的字段对应多条记录，不能用于排序
+func (sc *scope) orderable(name string) bool {
+	if !sc.distinct() {
+		return true
+	}
+	table, column, path := sc.resolve(name)
+	if len(sc.selected) == 0 {
+		return table == sc.name() && len(path) == 0
+	}
+	for _, field := range sc.selected {
+		t, c, p := sc.resolve(field)
+		if t == table && c == column && strings.Join(p, ".") == strings.Join(path, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// join 为查询添加连接表，使用左连接，保证按关联字段排序时不会丢失记录
+func (sc *scope) join(ds *goqu.SelectDataset) *goqu.SelectDataset {
+	for _, j := range sc.joins {
+		ds = ds.LeftJoin(
+			goqu.T(j.Table).As(j.Alias),
+			goqu.On(goqu.T(j.Alias).Col(j.Column).Eq(goqu.I(j.Ref))),
+		)
+	}
+	if sc.distinct() {
+		ds = ds.Distinct()
+	}
+	return ds
+}
+
+// selects 生成查询字段，连接表的字段及json路径使用原始的字段名作为列名，如：role.name，避免与本表字段冲突
+func (sc *scope) selects(selectFields []string) []interface{} {
+	sc.selected = selectFields
+	var selects []interface{}
+	for _, field := range selectFields {
+		table, column, path := sc.resolve(field)
+		switch {
+		case len(path) > 0:
+			selects = append(selects, sc.jsonProject(sc.ident(table, column), path).As(goqu.C(field)))
+		case table != "" && table != sc.name():
+			selects = append(selects, sc.ident(table, column).As(goqu.C(table+"."+column)))
+		default:
+			selects = append(selects, sc.ident(table, column))
+		}
+	}
+	if len(selects) == 0 && len(sc.joins) > 0 {
+		selects = append(selects, goqu.T(sc.name()).All())
+	}
+	return selects
+}
@@ -0,0 +1,17 @@
+// Package sqlite3 注册sqlite3方言，数据库驱动需要自行引入，如：_ "github.com/mattn/go-sqlite3"
+package sqlite3
+
+import (
+	"github.com/yaochi-tech/goqu"
+	_ "github.com/yaochi-tech/goqu/dialect/sqlite3"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+)
+
+var _ dialect.Dialect = (*dialect.DialectWrapper)(nil)
+
+func init() {
+	dialect.RegisterDialect(dialect.SQLITE3, &dialect.DialectWrapper{
+		Name:    dialect.SQLITE3,
+		Dialect: goqu.Dialect(dialect.SQLITE3),
+	})
+}
@@ -0,0 +1,17 @@
+// Package sqlserver 注册sql server方言，数据库驱动需要自行引入，如：_ "github.com/microsoft/go-mssqldb"
+package sqlserver
+
+import (
+	"github.com/yaochi-tech/goqu"
+	_ "github.com/yaochi-tech/goqu/dialect/sqlserver"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+)
+
+var _ dialect.Dialect = (*dialect.DialectWrapper)(nil)
+
+func init() {
+	dialect.RegisterDialect(dialect.SQLSERVER, &dialect.DialectWrapper{
+		Name:    dialect.SQLSERVER,
+		Dialect: goqu.Dialect(dialect.SQLSERVER),
+	})
+}
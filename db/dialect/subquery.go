@@ -0,0 +1,100 @@
+package dialect
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+)
+
+// SubQuery 子查询条件的值，由引擎根据模型生成，Field为子查询的字段，Where中可以包含$join及嵌套的子查询
+// 操作符为空或in时生成 字段 IN (SELECT Field ...)，not in 生成 NOT IN，
+// exists/not exists 生成关联子查询 EXISTS (SELECT 1 ... WHERE Field = 字段)，
+// 其他比较操作符将子查询作为单个值比较，如：amount > (SELECT AVG(amount) ...)
+type SubQuery struct {
+	Table string
+	Field string
+	Where map[string]interface{}
+}
+
+// subScope 生成子查询的scope，子查询中的字段总是带上表名，与外层为同一个表时使用别名
+func (sc *scope) subScope(sq SubQuery) *scope {
+	sub := &scope{dialect: sc.dialect, builder: sc.builder, table: sq.Table, qualify: true, depth: sc.depth + 1}
+	if sq.Table == sc.table {
+		sub.alias = sq.Table + "_" + strconv.Itoa(sub.depth)
+	}
+	if joins, ok := sq.Where[OP_JOIN].([]Join); ok {
+		// 连接表的关联字段为本表时，使用别名
+		for _, j := range joins {
+			if sub.alias != "" && strings.HasPrefix(j.Ref, sq.Table+".") {
+				j.Ref = sub.alias + strings.TrimPrefix(j.Ref, sq.Table)
+			}
+			sub.joins = append(sub.joins, j)
+		}
+	}
+	return sub
+}
+
+// subSelect 生成子查询语句，ref不为空时为关联子查询，子查询的Field与外层查询的ref字段相等
+func (sc *scope) subSelect(sq SubQuery, ref string) (*goqu.SelectDataset, error) {
+	sub := sc.subScope(sq)
+	from := goqu.T(sq.Table)
+	var ds *goqu.SelectDataset
+	if sub.alias != "" {
+		ds = sc.builder.From(from.As(sub.alias))
+	} else {
+		ds = sc.builder.From(from)
+	}
+
+	whereExList, err := whereExpression(sq.Where, sub)
+	if err != nil {
+		return nil, err
+	}
+	if ref != "" {
+		ds = ds.Select(goqu.L("1"))
+		table, column, path := sc.resolve(ref)
+		if table == "" {
+			table = sc.name()
+		}
+		outer := columnExpression(sc.ident(table, column))
+		if len(path) > 0 {
+			outer = sc.jsonValue(sc.ident(table, column), path, nil)
+		}
+		whereExList = append(whereExList, sub.column(sq.Field, nil).Eq(outer))
+	} else {
+		ds = ds.Select(sub.column(sq.Field, nil))
+	}
+	ds = sub.join(ds).Where(whereExList...)
+	return sub.options(ds, sq.Where)
+}
+
+// subQueryExpression 生成子查询条件，k为条件的key，如：id in、id exists
+func (sc *scope) subQueryExpression(k string, sq SubQuery) (goqu.Expression, error) {
+	field, op, _ := strings.Cut(k, " ")
+	ref := ""
+	switch strings.ToLower(op) {
+	case OP_EXISTS, OP_NOT_EXISTS:
+		ref = field
+	}
+	ds, err := sc.subSelect(sq, ref)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(op) {
+	case "", OP_IN:
+		// goqu的In会将子查询作为列表中的一个值，直接生成语句
+		return goqu.L("? IN ?", sc.column(field, nil), ds), nil
+	case OP_NOT_IN:
+		return goqu.L("? NOT IN ?", sc.column(field, nil), ds), nil
+	case OP_EXISTS:
+		return goqu.L("EXISTS ?", ds), nil
+	case OP_NOT_EXISTS:
+		return goqu.L("NOT EXISTS ?", ds), nil
+	}
+	// 子查询作为单个值比较
+	exList, err := whereExpression(map[string]interface{}{k: ds}, sc)
+	if err != nil {
+		return nil, err
+	}
+	return goqu.And(exList...), nil
+}
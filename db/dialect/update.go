@@ -0,0 +1,119 @@
+package dialect
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/goqu/exp"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+var (
+	ErrInvalidUpdate error = errors.New("invalid update")
+)
+
+// Expr sql表达式，作为更新的值时直接使用，如：Expr{SQL: "price * ?", Args: []interface{}{1.1}}
+// 表达式中的列名按数据库中的列名书写，不会转换为蛇形命名
+type Expr struct {
+	SQL  string
+	Args []interface{}
+}
+
+// updateRecord 将更新数据转换为goqu的Record，返回$non_negative对应的条件
+// key为 字段 或 字段 操作符，操作符为+、-时在原值上增减，如：{"viewCount +": 1} 生成 view_count = view_count + 1
+// $non_negative为增减后不能为负数的字段，结果为负数的行不更新，如：{"stock -": 2, "$non_negative": "stock"}
+func updateRecord(data map[string]interface{}) (goqu.Record, []goqu.Expression, error) {
+	record := make(goqu.Record, len(data))
+	deltas := make(map[string]goqu.Expression, len(data))
+	var guards []string
+	for k, v := range data {
+		if k == OP_NON_NEGATIVE {
+			guards = append(guards, toStrings(v)...)
+			continue
+		}
+		if e, ok := v.(Expr); ok {
+			v = goqu.L(e.SQL, e.Args...)
+		}
+
+		field, op, _ := strings.Cut(k, " ")
+		column := util.ToSnake(field)
+		switch op {
+		case "":
+			record[column] = v
+		case OP_INCR, OP_DECR:
+			delta := goqu.L("? "+op+" ?", goqu.I(column), v)
+			record[column] = delta
+			deltas[column] = delta
+		default:
+			return nil, nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidUpdate, k)
+		}
+	}
+
+	// 按字段排序，保证生成的sql稳定
+	sort.Strings(guards)
+	var where []goqu.Expression
+	for _, field := range guards {
+		delta, ok := deltas[util.ToSnake(field)]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s field %s has no + or - operation", ErrInvalidUpdate, OP_NON_NEGATIVE, field)
+		}
+		where = append(where, goqu.L("? >= 0", delta))
+	}
+	return record, where, nil
+}
+
+// BuildBulkUpdate 按主键批量更新各行不同的值，生成一条语句：
+// UPDATE table SET col = CASE pk WHEN 1 THEN v1 WHEN 2 THEN v2 ELSE col END WHERE pk IN (1, 2)
+// 每行必须包含主键且不能重复，某行没有的列保持原值；值可以是Expr，不支持增减操作符
+func (m *DialectWrapper) BuildBulkUpdate(tableName, pkColumn string, dataList []map[string]interface{}) (string, []interface{}, error) {
+	if len(dataList) == 0 {
+		return "", nil, fmt.Errorf("%w: no data", ErrInvalidUpdate)
+	}
+	ids := make([]interface{}, len(dataList))
+	seen := make(map[string]int, len(dataList))
+	cases := make(map[string]exp.CaseExpression)
+	for i, data := range dataList {
+		var id interface{}
+		values := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			if strings.Contains(k, " ") || strings.HasPrefix(k, "$") {
+				return "", nil, fmt.Errorf("%w: operation %q is not supported in bulk update", ErrInvalidUpdate, k)
+			}
+			if e, ok := v.(Expr); ok {
+				v = goqu.L(e.SQL, e.Args...)
+			}
+			if column := util.ToSnake(k); column == pkColumn {
+				id = v
+			} else {
+				values[column] = v
+			}
+		}
+		if id == nil {
+			return "", nil, fmt.Errorf("%w: row %d has no primary key", ErrInvalidUpdate, i)
+		}
+		// 主键重复时CASE中只有第一个WHEN生效，后面的值会被忽略
+		if j, ok := seen[fmt.Sprint(id)]; ok {
+			return "", nil, fmt.Errorf("%w: rows %d and %d have the same primary key %v", ErrInvalidUpdate, j, i, id)
+		}
+		seen[fmt.Sprint(id)] = i
+		ids[i] = id
+		for column, v := range values {
+			if cases[column] == nil {
+				cases[column] = goqu.Case().Value(goqu.I(pkColumn))
+			}
+			cases[column] = cases[column].When(id, v)
+		}
+	}
+	if len(cases) == 0 {
+		return "", nil, fmt.Errorf("%w: no columns to update", ErrInvalidUpdate)
+	}
+
+	record := make(goqu.Record, len(cases))
+	for column, c := range cases {
+		record[column] = c.Else(goqu.I(column))
+	}
+	return m.Dialect.Update(tableName).Set(record).Where(goqu.I(pkColumn).In(ids...)).ToSQL()
+}
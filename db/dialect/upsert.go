@@ -0,0 +1,146 @@
+package dialect
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+var (
+	ErrInvalidUpsert error = errors.New("invalid upsert")
+)
+
+// BuildUpsert 生成插入或更新的语句，conflictColumns为判断冲突的列，updateColumns为冲突时更新的列，列名为蛇形命名
+// mysql: INSERT ... ON DUPLICATE KEY UPDATE col = VALUES(col)，冲突的判断由表的主键及唯一索引决定
+// postgres/sqlite: INSERT ... ON CONFLICT (conflictColumns) DO UPDATE SET col = EXCLUDED.col
+// sql server: MERGE INTO ... USING (...) ON ... WHEN MATCHED THEN UPDATE ... WHEN NOT MATCHED THEN INSERT ...
+// updateColumns为空时冲突的行不做更新；incrColumns为冲突并更新时加1的列，如：乐观锁的版本号
+func (m *DialectWrapper) BuildUpsert(tableName string, dataList []map[string]interface{}, conflictColumns, updateColumns []string, incrColumns ...string) (string, []interface{}, error) {
+	if len(dataList) == 0 || len(conflictColumns) == 0 {
+		return "", nil, ErrInvalidUpsert
+	}
+	// dataList中的key转蛇形命名
+	var records []goqu.Record
+	for _, data := range dataList {
+		record := make(goqu.Record, len(data))
+		for k, v := range data {
+			record[util.ToSnake(k)] = v
+		}
+		records = append(records, record)
+	}
+
+	if m.Name == SQLSERVER {
+		return m.buildMerge(tableName, records, conflictColumns, updateColumns, incrColumns)
+	}
+
+	rows := make([]interface{}, len(records))
+	for i, record := range records {
+		rows[i] = record
+	}
+	// goqu在有冲突处理时会生成INSERT IGNORE，会忽略其他错误，冲突处理的语句单独生成
+	sql, args, err := m.Dialect.Insert(tableName).Rows(rows...).ToSQL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var set []string
+	for _, column := range updateColumns {
+		if m.Name == MYSQL {
+			set = append(set, m.quote(column)+" = VALUES("+m.quote(column)+")")
+		} else {
+			set = append(set, m.quote(column)+" = excluded."+m.quote(column))
+		}
+	}
+	for _, column := range incrColumns {
+		if len(set) == 0 {
+			break
+		}
+		if m.Name == MYSQL {
+			set = append(set, m.quote(column)+" = "+m.quote(column)+" + 1")
+		} else {
+			// excluded中也有同名的列，原有的行需要带上表名
+			set = append(set, m.quote(column)+" = "+m.quote(tableName)+"."+m.quote(column)+" + 1")
+		}
+	}
+	if m.Name == MYSQL {
+		if len(set) == 0 {
+			// 更新为原值，冲突的行不做修改
+			set = append(set, m.quote(conflictColumns[0])+" = "+m.quote(conflictColumns[0]))
+		}
+		return sql + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", "), args, nil
+	}
+
+	quoted := make([]string, len(conflictColumns))
+	for i, column := range conflictColumns {
+		quoted[i] = m.quote(column)
+	}
+	sql += " ON CONFLICT (" + strings.Join(quoted, ", ") + ")"
+	if len(set) == 0 {
+		return sql + " DO NOTHING", args, nil
+	}
+	return sql + " DO UPDATE SET " + strings.Join(set, ", "), args, nil
+}
+
+// buildMerge 生成sql server的MERGE语句，数据行通过UNION ALL作为源表
+func (m *DialectWrapper) buildMerge(tableName string, records []goqu.Record, conflictColumns, updateColumns, incrColumns []string) (string, []interface{}, error) {
+	// 所有行的列，按列名排序
+	columnSet := make(map[string]bool)
+	for _, record := range records {
+		for column := range record {
+			columnSet[column] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for column := range columnSet {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var source *goqu.SelectDataset
+	for _, record := range records {
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = goqu.V(record[column]).As(column)
+		}
+		if ds := m.Dialect.Select(values...); source == nil {
+			source = ds
+		} else {
+			source = source.UnionAll(ds)
+		}
+	}
+	sourceSQL, _, err := source.ToSQL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	quoted := make([]string, len(columns))
+	sourceValues := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = m.quote(column)
+		sourceValues[i] = "source." + m.quote(column)
+	}
+	var on []string
+	for _, column := range conflictColumns {
+		on = append(on, "target."+m.quote(column)+" = source."+m.quote(column))
+	}
+
+	var sql strings.Builder
+	sql.WriteString("MERGE INTO " + m.quote(tableName) + " AS target USING (" + sourceSQL + ") AS source")
+	sql.WriteString(" ON (" + strings.Join(on, " AND ") + ")")
+	if len(updateColumns) > 0 {
+		var set []string
+		for _, column := range updateColumns {
+			set = append(set, "target."+m.quote(column)+" = source."+m.quote(column))
+		}
+		for _, column := range incrColumns {
+			set = append(set, "target."+m.quote(column)+" = target."+m.quote(column)+" + 1")
+		}
+		sql.WriteString(" WHEN MATCHED THEN UPDATE SET " + strings.Join(set, ", "))
+	}
+	sql.WriteString(" WHEN NOT MATCHED THEN INSERT (" + strings.Join(quoted, ", ") + ")")
+	sql.WriteString(" VALUES (" + strings.Join(sourceValues, ", ") + ");")
+	return sql.String(), nil, nil
+}
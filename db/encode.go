@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yaochi-tech/goqu/exp"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+// encodeRow 按照模型字段类型转换写入的数据，返回新的map，不修改传入的数据
+// key可以是字段名、列名或 字段 操作符（更新时的增减），非模型字段、$开头的选项及sql表达式原样保留
+func encodeRow(s *schema.Schema, row map[string]interface{}) (map[string]interface{}, error) {
+	encoded := make(map[string]interface{}, len(row))
+	for k, v := range row {
+		encoded[k] = v
+		if strings.HasPrefix(k, "$") || isExpression(v) {
+			continue
+		}
+		name, _, _ := strings.Cut(k, " ")
+		field := s.GetFieldByColumn(util.ToSnake(name))
+		if field == nil {
+			continue
+		}
+		value, err := schema.EncodeValue(field.ValueType(), v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		encoded[k] = value
+	}
+	return encoded, nil
+}
+
+// insertRows 转换插入的各行数据，缺少的字段使用模型的默认值，default_raw为数据库表达式，仍由数据库处理
+func insertRows(s *schema.Schema, data []map[string]interface{}) ([]map[string]interface{}, error) {
+	rows := make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		withDefaults := make(map[string]interface{}, len(s.Fields))
+		for k, v := range row {
+			withDefaults[k] = v
+		}
+		for _, field := range s.Fields {
+			if field.Default == "" || field.IsDefaultRaw {
+				continue
+			}
+			if key, _ := fieldValue(field, row); key == "" {
+				withDefaults[field.Name] = field.Default
+			}
+		}
+
+		encoded, err := encodeRow(s, withDefaults)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		rows[i] = encoded
+	}
+	return rows, nil
+}
+
+// isExpression 判断是否为直接使用的sql表达式
+func isExpression(v interface{}) bool {
+	switch v.(type) {
+	case Expr, exp.Expression:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"testing"
+)
+
+func TestEngine_EncodeRow(t *testing.T) {
+	Convey("写入时转换字段类型", t, func() {
+		engine := newTestEngine(roleDef, memberDef, postDef, tagDef, settingDef)
+
+		Convey("插入", func() {
+			data := map[string]interface{}{"id": float64(1), "roleId": "2", "profile": map[string]interface{}{"city": "杭州"}}
+			sql, _, err := engine.InsertSQL("member", data)
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "INSERT INTO `member` (`id`, `profile`, `role_id`) VALUES (1, '{\\\"city\\\":\\\"杭州\\\"}', 2)")
+			So(data["id"], ShouldEqual, float64(1))
+		})
+
+		Convey("补齐默认值", func() {
+			sql, _, err := engine.InsertSQL("setting", map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "INSERT INTO `setting` (`enabled`, `id`) VALUES (1, 1)")
+		})
+
+		Convey("更新", func() {
+			sql, _, err := engine.UpdateSQL("member", map[string]interface{}{"roleId +": float64(1), "name": 12}, map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "UPDATE `member` SET `name`='12',`role_id`=`role_id` + 1 WHERE (`id` = 1)")
+		})
+
+		Convey("无法转换的值", func() {
+			_, _, err := engine.InsertSQL("member", map[string]interface{}{"id": 1, "roleId": "abc"})
+			So(err, ShouldWrap, schema.ErrInvalidValue)
+			_, _, err = engine.UpdateSQL("member", map[string]interface{}{"profile": "{"}, map[string]interface{}{"id": 1})
+			So(err, ShouldWrap, schema.ErrInvalidValue)
+		})
+	})
+}
@@ -1,21 +1,41 @@
 package db
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
 	"github.com/yaochi-tech/lingquan-core-go/db/schema"
-	"github.com/yaochi-tech/lingquan-core-go/util"
 	"sync"
 )
 
 var (
 	ErrSchemaNotRegistered error = errors.New("schema not registered")
+	ErrQueryNotFound       error = errors.New("query not found")
+	ErrRecordNotFound      error = errors.New("record not found")
+	ErrEngineOffline       error = errors.New("engine is offline")
+	ErrLockWithoutTx       error = errors.New("lock requires a transaction")
+	// ErrStaleRecord 乐观锁更新时版本号不匹配，数据已被其他人修改或已删除
+	ErrStaleRecord error = errors.New("stale record")
+	// ErrTooManyAffected 更新或删除影响的行数超过条件中的$max_affected
+	ErrTooManyAffected error = errors.New("too many rows affected")
+	// ErrAffectedMismatch UpdateReturning按主键更新时影响的行数与锁定的行数不一致
+	ErrAffectedMismatch error = errors.New("affected rows mismatch")
+	// ErrDeleteRestricted 关系的onDelete为restrict且存在关联数据，不能删除
+	ErrDeleteRestricted error = errors.New("delete restricted by relation")
+	// ErrCascadeTooDeep 级联删除超过MAX_CASCADE_DEPTH层
+	ErrCascadeTooDeep error = errors.New("cascade too deep")
+	// ErrInvalidGraph InsertGraph/UpdateGraph的关联数据不正确，如：关系不是hasOne/hasMany、有关联数据的行没有主键
+	ErrInvalidGraph error = errors.New("invalid graph")
+	// ErrStopIteration FindEach的回调返回该错误时停止读取，FindEach返回nil
+	ErrStopIteration error = errors.New("stop iteration")
 )
 
 // Engine 数据库引擎, 该引擎通过解析模型json文件, 生成对应的数据库表，并对表进行增删改查操作
 type Engine struct {
 	DB              *sqlx.DB
+	driverName      string
 	dialect         dialect.Dialect
 	currentDatabase string
 	schemas         map[string]*schema.Schema // 模型名(code) => 模型
@@ -23,6 +43,10 @@ type Engine struct {
 }
 
 func NewEngine(driverName, dataSourceName string) (*Engine, error) {
+	engine, err := NewOfflineEngine(driverName)
+	if err != nil {
+		return nil, err
+	}
 	db, err := sqlx.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
@@ -30,15 +54,7 @@ func NewEngine(driverName, dataSourceName string) (*Engine, error) {
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
-	engine := new(Engine)
 	engine.DB = db
-	engine.schemas = make(map[string]*schema.Schema)
-	var ok bool
-	engine.dialect, ok = dialect.GetDialect(driverName)
-	if !ok {
-		err = dialect.ErrDialectNotSupported
-		return nil, err
-	}
 
 	// 查询当前数据库
 	sql := engine.dialect.CurrentDatabaseSQL()
@@ -50,17 +66,66 @@ func NewEngine(driverName, dataSourceName string) (*Engine, error) {
 	return engine, nil
 }
 
+// NewOfflineEngine 创建不连接数据库的引擎，dialectName为方言名称，如：mysql
+// 离线引擎只能用于注册模型及生成sql语句（InsertSQL、FindSQL等），执行语句时返回ErrEngineOffline
+func NewOfflineEngine(dialectName string) (*Engine, error) {
+	d, ok := dialect.GetDialect(dialectName)
+	if !ok {
+		return nil, dialect.ErrDialectNotSupported
+	}
+	return &Engine{
+		driverName: dialectName,
+		dialect:    d,
+		schemas:    make(map[string]*schema.Schema),
+	}, nil
+}
+
 // Close 关闭数据库连接
 func (engine *Engine) Close() {
-	_ = engine.DB.Close()
+	if engine.DB != nil {
+		_ = engine.DB.Close()
+	}
 }
 
-// Register 注册模型
+// executor 执行语句的数据库连接或事务
+type executor interface {
+	sqlx.Ext
+	sqlx.ExtContext
+}
+
+// executor 返回执行语句的对象，传入事务时使用事务，离线引擎返回ErrEngineOffline
+func (engine *Engine) executor(tx ...*sqlx.Tx) (executor, error) {
+	if len(tx) > 0 && tx[0] != nil {
+		return tx[0], nil
+	}
+	if engine.DB == nil {
+		return nil, ErrEngineOffline
+	}
+	return engine.DB, nil
+}
+
+// queryExecutor 返回执行查询的对象，条件中有$lock时必须在事务中执行
+func (engine *Engine) queryExecutor(namedCondition map[string]interface{}, tx ...*sqlx.Tx) (executor, error) {
+	if _, ok := namedCondition[dialect.OP_LOCK]; ok && (len(tx) == 0 || tx[0] == nil) {
+		return nil, ErrLockWithoutTx
+	}
+	return engine.executor(tx...)
+}
+
+// rebind 将语句中的?占位符转换为驱动对应的占位符，如：postgres为$1
+func (engine *Engine) rebind(sql string) string {
+	return sqlx.Rebind(sqlx.BindType(engine.driverName), sql)
+}
+
+// Register 注册模型，模型中定义的自定义查询会在注册时校验
 func (engine *Engine) Register(definition string) (string, error) {
+	s := schema.Parse(definition)
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
 	// 加锁
 	engine.lock.Lock()
 	defer engine.lock.Unlock()
-	s := schema.Parse(definition)
 	engine.schemas[s.Name] = s // 这里的Name是模型名(code)
 	return s.Name, nil
 }
@@ -72,6 +137,15 @@ func (engine *Engine) GetSchema(name string) *schema.Schema {
 	return engine.schemas[name]
 }
 
+// schemaOf 获取模型，模型未注册时返回ErrSchemaNotRegistered
+func (engine *Engine) schemaOf(name string) (*schema.Schema, error) {
+	s := engine.GetSchema(name)
+	if s == nil {
+		return nil, ErrSchemaNotRegistered
+	}
+	return s, nil
+}
+
 // GetSchemas 获取所有模型
 func (engine *Engine) GetSchemas() map[string]*schema.Schema {
 	engine.lock.RLock()
@@ -81,194 +155,503 @@ func (engine *Engine) GetSchemas() map[string]*schema.Schema {
 
 // SchemaTableExists 检查模型对应的表格是否存在
 func (engine *Engine) SchemaTableExists(name string, tx ...*sqlx.Tx) (bool, error) {
-	s := engine.GetSchema(name)
-	if s == nil {
-		// 抛出异常，模型不存在
-		return false, ErrSchemaNotRegistered
+	return engine.SchemaTableExistsContext(context.Background(), name, tx...)
+}
+
+// SchemaTableExistsContext 同SchemaTableExists，ctx用于取消及超时
+func (engine *Engine) SchemaTableExistsContext(ctx context.Context, name string, tx ...*sqlx.Tx) (bool, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return false, err
 	}
-	sql, args := engine.dialect.TableExistSQL(s.Name, engine.currentDatabase)
+	return engine.tableExists(ctx, s.TableName, tx...)
+}
 
-	var row *sqlx.Row
-	if len(tx) > 0 {
-		row = tx[0].QueryRowx(sql, args...)
-	} else {
-		row = engine.DB.QueryRowx(sql, args...)
+// tableExists 检查表格是否存在
+func (engine *Engine) tableExists(ctx context.Context, tableName string, tx ...*sqlx.Tx) (bool, error) {
+	sql, args := engine.dialect.TableExistSQL(tableName, engine.currentDatabase)
+	return engine.exists(ctx, sql, args, tx...)
+}
+
+// exists 执行只查询一个名称的语句，查询到名称时返回true
+func (engine *Engine) exists(ctx context.Context, sql string, args []interface{}, tx ...*sqlx.Tx) (bool, error) {
+	ex, err := engine.executor(tx...)
+	if err != nil {
+		return false, err
 	}
+
+	row := ex.QueryRowxContext(ctx, engine.rebind(sql), args...)
 	if row.Err() != nil {
 		return false, row.Err()
 	}
 
-	var tableName string
-	err := row.Scan(&tableName)
+	var name string
+	err = row.Scan(&name)
 	if err != nil {
 		return false, nil
 	}
-	return tableName != "", nil
+	return name != "", nil
 }
 
-// MigrateTable 迁移表
+// MigrateTable 迁移表，多对多关系的中间表及缺失的索引一并建立，可以重复执行
 func (engine *Engine) MigrateTable(name string, tx ...*sqlx.Tx) error {
-	s := engine.GetSchema(name)
-	if s == nil {
-		return nil
+	return engine.MigrateTableContext(context.Background(), name, tx...)
+}
+
+// MigrateTableContext 同MigrateTable，ctx用于取消及超时
+func (engine *Engine) MigrateTableContext(ctx context.Context, name string, tx ...*sqlx.Tx) error {
+	_, err := engine.migrateTable(ctx, name, tx...)
+	return err
+}
+
+// migrateTable 迁移表，返回MIGRATE_CREATED、MIGRATE_ALTERED（表已存在，建立了中间表或缺失的索引）或MIGRATE_UNCHANGED
+// mysql的ddl会自动提交，建表后建立索引失败时表已存在，重新迁移时会补建缺失的索引
+func (engine *Engine) migrateTable(ctx context.Context, name string, tx ...*sqlx.Tx) (string, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", err
+	}
+	ex, err := engine.executor(tx...)
+	if err != nil {
+		return "", err
 	}
 	// 先查看是否存在表
-	tableExists, err := engine.SchemaTableExists(name, tx...)
+	tableExists, err := engine.tableExists(ctx, s.TableName, tx...)
 	if err != nil {
-		return err
+		return "", err
 	}
 
+	status := MIGRATE_UNCHANGED
+	var statements []dialect.IndexStatement
 	if !tableExists {
 		// 如果不存在表，则创建表
-		sql := engine.dialect.CreateTableSQL(s)
-		if len(tx) > 0 {
-			_, err = tx[0].Exec(sql)
-		} else {
-			_, err = engine.DB.Exec(sql)
+		if _, err = ex.ExecContext(ctx, engine.dialect.CreateTableSQL(s)); err != nil {
+			return "", err
 		}
+		status = MIGRATE_CREATED
+	} else {
+		// 唯一约束只在建表时建立，表已存在时补建缺失的唯一索引，upsert默认以唯一字段判断冲突
+		// 已有重复数据时建立失败，返回错误
+		statements = engine.dialect.UniqueIndexStatements(s)
+	}
+	// 字段等变化暂不处理，只建立缺失的普通索引及全文索引
+	statements = append(statements, engine.dialect.IndexStatements(s)...)
+	statements = append(statements, engine.dialect.FulltextIndexStatements(s)...)
+	created, err := engine.migrateIndexes(ctx, s.TableName, statements, tx...)
+	if err != nil {
+		return "", err
+	}
+	pivotCreated, err := engine.migratePivots(ctx, s, tx...)
+	if err != nil {
+		return "", err
+	}
+	if (created || pivotCreated) && status == MIGRATE_UNCHANGED {
+		status = MIGRATE_ALTERED
+	}
+	return status, nil
+}
+
+// migratePivots 建立模型的多对多关系中不存在的中间表及索引，返回是否建立了中间表或索引
+func (engine *Engine) migratePivots(ctx context.Context, s *schema.Schema, tx ...*sqlx.Tx) (bool, error) {
+	pivots, err := engine.pivotSchemas(s)
+	if err != nil {
+		return false, err
+	}
+	ex, err := engine.executor(tx...)
+	if err != nil {
+		return false, err
+	}
+	created := false
+	for _, pivot := range pivots {
+		exists, err := engine.tableExists(ctx, pivot.TableName, tx...)
 		if err != nil {
-			return err
+			return false, err
 		}
-	} else {
-		// 如果表已经存在，则检查字段、索引等是否有变化
-		// TODO
+		if !exists {
+			if _, err = ex.ExecContext(ctx, engine.dialect.CreateTableSQL(pivot)); err != nil {
+				return false, err
+			}
+			created = true
+		}
+		indexCreated, err := engine.migrateIndexes(ctx, pivot.TableName, engine.dialect.IndexStatements(pivot), tx...)
+		if err != nil {
+			return false, err
+		}
+		created = created || indexCreated
 	}
+	return created, nil
+}
 
-	return err
+// migrateIndexes 建立不存在的索引，返回是否建立了索引
+func (engine *Engine) migrateIndexes(ctx context.Context, table string, statements []dialect.IndexStatement, tx ...*sqlx.Tx) (bool, error) {
+	ex, err := engine.executor(tx...)
+	if err != nil {
+		return false, err
+	}
+	created := false
+	for _, statement := range statements {
+		sql, args := engine.dialect.IndexExistSQL(table, statement.Name, engine.currentDatabase)
+		exists, err := engine.exists(ctx, sql, args, tx...)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			continue
+		}
+		for _, sql := range statement.SQL {
+			if _, err = ex.ExecContext(ctx, sql); err != nil {
+				return false, err
+			}
+		}
+		created = true
+	}
+	return created, nil
 }
 
 // DropTable 删除表
 func (engine *Engine) DropTable(name string) error {
-	s := engine.GetSchema(name)
-	if s == nil {
-		return nil
+	return engine.DropTableContext(context.Background(), name)
+}
+
+// DropTableContext 同DropTable，ctx用于取消及超时
+func (engine *Engine) DropTableContext(ctx context.Context, name string) error {
+	sql, err := engine.DropTableSQL(name)
+	if err != nil {
+		return err
 	}
-	sql := engine.dialect.DropTableSQL(s)
-	_, err := engine.DB.Exec(sql)
+	ex, err := engine.executor()
+	if err != nil {
+		return err
+	}
+	_, err = ex.ExecContext(ctx, sql)
 	return err
 }
 
-// Migrate 迁移所有注册的模型
-func (engine *Engine) Migrate() error {
-	if len(engine.schemas) == 0 {
-		return nil
+// Insert 插入数据，返回各行的主键，顺序与data相同
+// 主键字段配置了生成器时，缺少主键的行自动生成主键，不会修改传入的data；没有生成器且未传入主键的行返回nil
+func (engine *Engine) Insert(name string, data ...map[string]interface{}) ([]interface{}, error) {
+	return engine.InsertContext(context.Background(), name, data...)
+}
+
+// InsertContext 同Insert，ctx用于取消及超时
+func (engine *Engine) InsertContext(ctx context.Context, name string, data ...map[string]interface{}) ([]interface{}, error) {
+	return engine.insert(ctx, name, data)
+}
+
+// insert 插入数据，Session在事务中插入时传入tx
+func (engine *Engine) insert(ctx context.Context, name string, data []map[string]interface{}, tx ...*sqlx.Tx) ([]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
 	}
-	tx, err := engine.DB.Beginx()
+	sql, args, ids, err := engine.buildInsert(s, data)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for name := range engine.schemas {
-		err = engine.MigrateTable(name, tx)
-		if err != nil {
-			return err
-		}
+	if _, err = engine.exec(ctx, sql, args, tx...); err != nil {
+		return nil, err
 	}
-	return tx.Commit()
+	return ids, nil
 }
 
-// Insert 插入数据
-func (engine *Engine) Insert(name string, data ...map[string]interface{}) (int64, error) {
-	s := engine.GetSchema(name)
-	if s == nil {
-		return 0, nil
+// Find 查询数据, where中的条件使用命名参数，如：where = "id = :id", namedCondition = map[string]interface{}{"id": 1}
+// 条件、排序及查询字段可以使用 关系名.字段 引用关联模型的字段，如：role.name，会根据模型关系自动连接关联表
+// 条件的值可以是SubQuery，以其他模型的查询作为条件
+// 条件中的$lock为行锁，只能在事务中使用，如：engine.Find("account", map[string]interface{}{"id": 1, "$lock": "update"}, nil, tx)
+func (engine *Engine) Find(name string, namedCondition map[string]interface{}, selectFields []string, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	return engine.FindContext(context.Background(), name, namedCondition, selectFields, tx...)
+}
+
+// FindContext 同Find，ctx用于取消及超时
+func (engine *Engine) FindContext(ctx context.Context, name string, namedCondition map[string]interface{}, selectFields []string, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
 	}
 
-	// BuildInsert会对data的key转换为蛇形命名
-	sql, args, err := engine.dialect.BuildInsert(s.TableName, data)
+	sql, args, related, err := engine.buildSelect(s, namedCondition, selectFields)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	ex, err := engine.queryExecutor(namedCondition, tx...)
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := engine.DB.Exec(sql, args...)
+	rows, err := ex.QueryxContext(ctx, sql, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return res.RowsAffected()
+	return scanRows(s, related, rows)
 }
 
-// Find 查询数据, where中的条件使用命名参数，如：where = "id = :id", namedCondition = map[string]interface{}{"id": 1}
-func (engine *Engine) Find(name string, namedCondition map[string]interface{}, selectFields []string) ([]map[string]interface{}, error) {
-	s := engine.GetSchema(name)
-	if s == nil {
-		return nil, nil
-	}
+// FindOne 查询一条数据，条件及查询字段同Find，没有符合条件的数据时返回ErrRecordNotFound
+func (engine *Engine) FindOne(name string, namedCondition map[string]interface{}, selectFields []string, tx ...*sqlx.Tx) (map[string]interface{}, error) {
+	return engine.FindOneContext(context.Background(), name, namedCondition, selectFields, tx...)
+}
 
-	// namedCondition中的key转换为蛇形命名
-	where := make(map[string]interface{}, len(namedCondition))
+// FindOneContext 同FindOne，ctx用于取消及超时
+func (engine *Engine) FindOneContext(ctx context.Context, name string, namedCondition map[string]interface{}, selectFields []string, tx ...*sqlx.Tx) (map[string]interface{}, error) {
+	// 复制条件，只查询一条
+	where := make(map[string]interface{}, len(namedCondition)+1)
 	for k, v := range namedCondition {
-		where[util.ToSnake(k)] = v
+		where[k] = v
+	}
+	where[dialect.OP_LIMIT] = 1
+
+	rows, err := engine.FindContext(ctx, name, where, selectFields, tx...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return rows[0], nil
+}
+
+// FindByID 根据主键查询一条数据，没有对应的数据时返回ErrRecordNotFound
+func (engine *Engine) FindByID(name string, id interface{}, selectFields []string, tx ...*sqlx.Tx) (map[string]interface{}, error) {
+	return engine.FindByIDContext(context.Background(), name, id, selectFields, tx...)
+}
+
+// FindByIDContext 同FindByID，ctx用于取消及超时
+func (engine *Engine) FindByIDContext(ctx context.Context, name string, id interface{}, selectFields []string, tx ...*sqlx.Tx) (map[string]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	pk := s.PrimaryKey()
+	if pk == nil {
+		return nil, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidField, s.Name)
+	}
+	return engine.FindOneContext(ctx, name, map[string]interface{}{pk.Column: id}, selectFields, tx...)
+}
+
+// FindEach 逐行查询数据，每读取一行按照模型字段类型解码后调用fn，不会将所有结果加载到内存中，适用于导出等大量数据的场景
+// fn返回ErrStopIteration时停止读取并返回nil，返回其他错误时停止读取并返回该错误，条件及查询字段同Find
+func (engine *Engine) FindEach(name string, namedCondition map[string]interface{}, selectFields []string, fn func(row map[string]interface{}) error, tx ...*sqlx.Tx) error {
+	return engine.FindEachContext(context.Background(), name, namedCondition, selectFields, fn, tx...)
+}
+
+// FindEachContext 同FindEach，ctx取消时停止读取并返回ctx的错误
+func (engine *Engine) FindEachContext(ctx context.Context, name string, namedCondition map[string]interface{}, selectFields []string, fn func(row map[string]interface{}) error, tx ...*sqlx.Tx) error {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return err
+	}
+
+	sql, args, related, err := engine.buildSelect(s, namedCondition, selectFields)
+	if err != nil {
+		return err
+	}
+	ex, err := engine.queryExecutor(namedCondition, tx...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := ex.QueryxContext(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	err = eachRow(s, related, rows, fn)
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	if err == nil {
+		// 读取过程中ctx被取消时，rows.Err()可能返回nil
+		err = ctx.Err()
+	}
+	return err
+}
+
+// buildSelect 生成查询语句，返回的map为关联查询中的关联模型
+func (engine *Engine) buildSelect(s *schema.Schema, namedCondition map[string]interface{}, selectFields []string) (string, []interface{}, map[string]*schema.Schema, error) {
+	// 复制条件并转换其中的子查询，BuildSelect会将字段转换为蛇形命名，json路径保持原样
+	where, err := engine.resolveSubQueries(namedCondition)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	// 关系名.字段 形式的引用需要连接关联表
+	joins, related, err := engine.resolveJoins(s, where, selectFields)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(joins) > 0 {
+		where[dialect.OP_JOIN] = joins
 	}
 
 	sql, args, err := engine.dialect.BuildSelect(s.TableName, selectFields, where)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return sql, args, related, nil
+}
+
+// Query 执行模型json中定义的自定义查询，params为查询参数，结果按照模型字段类型解码
+func (engine *Engine) Query(name, queryName string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return engine.QueryContext(context.Background(), name, queryName, params)
+}
+
+// QueryContext 同Query，ctx用于取消及超时
+func (engine *Engine) QueryContext(ctx context.Context, name, queryName string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return engine.query(ctx, name, queryName, params)
+}
+
+// query 执行自定义查询，Session在事务中查询时传入tx
+func (engine *Engine) query(ctx context.Context, name, queryName string, params map[string]interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	sql, args, err := engine.QuerySQL(name, queryName, params)
+	if err != nil {
+		return nil, err
+	}
+	ex, err := engine.executor(tx...)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := engine.DB.Queryx(sql, args...)
+	rows, err := ex.QueryxContext(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanRows(s, nil, rows)
+}
 
+// scanRows 读取查询结果，并按照模型字段类型解码，读取完成后关闭rows，related为关联查询中的关联模型
+func scanRows(s *schema.Schema, related map[string]*schema.Schema, rows *sqlx.Rows) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
+	err := eachRow(s, related, rows, func(row map[string]interface{}) error {
+		results = append(results, row)
+		return nil
+	})
+	return results, err
+}
+
+// eachRow 逐行读取查询结果，按照模型字段类型解码后调用fn，fn返回错误时停止读取，读取完成后关闭rows
+func eachRow(s *schema.Schema, related map[string]*schema.Schema, rows *sqlx.Rows, fn func(row map[string]interface{}) error) error {
+	defer rows.Close()
+
 	for rows.Next() {
-		result := make(map[string]interface{})
-		err = rows.MapScan(result)
-		if err != nil {
-			return nil, err
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return err
+		}
+		if err := decodeRow(s, related, row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
 		}
-		results = append(results, result)
 	}
-	return results, nil
+	return rows.Err()
 }
 
 // Update 更新数据, where中的条件使用命名参数，如：where = "id = :id", namedCondition = map[string]interface{}{"id": 1}
-func (engine *Engine) Update(name string, data, namedCondition map[string]interface{}) (int64, error) {
-	s := engine.GetSchema(name)
-	if s == nil {
-		return 0, nil
-	}
+// 模型有版本号字段时，版本号加1；data中有版本号时作为条件，没有更新任何行时返回ErrStaleRecord
+// 条件编译后为空时返回dialect.ErrEmptyWhere，更新所有行时条件中使用"$all": true；
+// 条件中的$max_affected为允许影响的最大行数，超过时回滚并返回ErrTooManyAffected，传入事务时由调用者回滚
+func (engine *Engine) Update(name string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	return engine.UpdateContext(context.Background(), name, data, namedCondition, tx...)
+}
 
-	where := make(map[string]interface{}, len(namedCondition))
-	for k, v := range namedCondition {
-		where[util.ToSnake(k)] = v
+// UpdateContext 同Update，ctx用于取消及超时
+func (engine *Engine) UpdateContext(ctx context.Context, name string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return 0, err
 	}
-
-	sql, args, err := engine.dialect.BuildUpdate(s.TableName, where, data)
+	data, namedCondition, checked, err := updateRow(s, data, namedCondition)
 	if err != nil {
 		return 0, err
 	}
-
-	res, err := engine.DB.Exec(sql, args...)
+	sql, args, err := engine.buildUpdate(s, data, namedCondition)
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	count, err := engine.execBounded(ctx, sql, args, namedCondition, tx...)
+	if err == nil && count == 0 && checked {
+		return 0, ErrStaleRecord
+	}
+	return count, err
 }
 
-// Delete 删除数据, where中的条件使用命名参数，如：where = "id = :id", namedCondition = map[string]interface{}{"id": 1}，注意，delete方法必须有where条件
-func (engine *Engine) Delete(name string, namedCondition map[string]interface{}) (int64, error) {
-	s := engine.GetSchema(name)
-	if s == nil {
-		return 0, nil
+// Delete 删除数据, where中的条件使用命名参数，如：where = "id = :id", namedCondition = map[string]interface{}{"id": 1}
+// 注意，delete方法必须有where条件，删除所有行时条件中使用"$all": true；$max_affected同Update
+// 模型的关系配置了onDelete时，在事务中先处理关联数据再删除，$max_affected只限制本模型删除的行数
+func (engine *Engine) Delete(name string, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	return engine.DeleteContext(context.Background(), name, namedCondition, tx...)
+}
+
+// DeleteContext 同Delete，ctx用于取消及超时
+func (engine *Engine) DeleteContext(ctx context.Context, name string, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	sql, args, err := engine.DeleteSQL(name, namedCondition)
+	if err != nil {
+		return 0, err
+	}
+	s, _ := engine.schemaOf(name)
+	if !hasOnDelete(s) {
+		return engine.execBounded(ctx, sql, args, namedCondition, tx...)
+	}
+	limit, bounded, err := maxAffected(namedCondition)
+	if err != nil {
+		return 0, err
 	}
 
-	// 判断where条件是否存在
-	if len(namedCondition) == 0 {
-		return 0, errors.New("delete method must have where condition")
+	var ownTx *sqlx.Tx
+	if len(tx) == 0 || tx[0] == nil {
+		if engine.DB == nil {
+			return 0, ErrEngineOffline
+		}
+		if ownTx, err = engine.DB.BeginTxx(ctx, nil); err != nil {
+			return 0, err
+		}
+		tx = []*sqlx.Tx{ownTx}
+	}
+	count, err := engine.deleteCascade(ctx, s, namedCondition, 0, tx[0])
+	if err == nil && bounded && count > limit {
+		err = fmt.Errorf("%w: %d rows affected, limit is %d", ErrTooManyAffected, count, limit)
 	}
+	if ownTx != nil {
+		if err != nil {
+			_ = ownTx.Rollback()
+			return 0, err
+		}
+		if err = ownTx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return count, err
+}
 
-	where := make(map[string]interface{}, len(namedCondition))
-	for k, v := range namedCondition {
-		where[util.ToSnake(k)] = v
+// withTx 在事务中执行fn，未传入事务时开启新事务，fn返回错误时回滚，否则提交；传入事务时由调用者提交或回滚
+func (engine *Engine) withTx(ctx context.Context, tx []*sqlx.Tx, fn func(tx *sqlx.Tx) error) error {
+	if len(tx) > 0 && tx[0] != nil {
+		return fn(tx[0])
+	}
+	if engine.DB == nil {
+		return ErrEngineOffline
 	}
+	ownTx, err := engine.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(ownTx); err != nil {
+		_ = ownTx.Rollback()
+		return err
+	}
+	return ownTx.Commit()
+}
 
-	sql, args, err := engine.dialect.BuildDelete(s.TableName, where)
+// exec 执行语句，返回影响的行数
+func (engine *Engine) exec(ctx context.Context, sql string, args []interface{}, tx ...*sqlx.Tx) (int64, error) {
+	ex, err := engine.executor(tx...)
 	if err != nil {
 		return 0, err
 	}
-
-	res, err := engine.DB.Exec(sql, args...)
+	res, err := ex.ExecContext(ctx, sql, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -1,9 +1,13 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
+	"time"
 )
 
 const def = `{
@@ -198,6 +202,21 @@ const def = `{
     "timestamps": true,
     "softDelete": true
   },
+  "queries": [
+    {
+      "name": "byUsername",
+      "label": "按用户名查询",
+      "where": {"username": ":username"},
+      "fields": ["id", "username", "nickname"],
+      "params": [{"name": "username", "type": "string", "required": true}]
+    },
+    {
+      "name": "countByGender",
+      "label": "按性别统计",
+      "sql": "SELECT gender, COUNT(*) AS total FROM user WHERE gender IN (:genders) GROUP BY gender",
+      "params": [{"name": "genders", "type": "string", "array": true, "default": ["男", "女", "保密"]}]
+    }
+  ],
   "values": [
     {
       "username": "admin",
@@ -283,3 +302,692 @@ func TestEngine_Insert(t *testing.T) {
 		So(err, ShouldBeNil)
 	})
 }
+
+func TestEngine_Query(t *testing.T) {
+	Convey("自定义查询测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		_, err = engine.Insert("user", map[string]interface{}{
+			"id":       1,
+			"username": "test",
+			"password": "123456",
+			"nickname": "测试用户",
+			"email":    "test@test.test",
+			"mobile":   "13800138000",
+		})
+		So(err, ShouldBeNil)
+
+		// 条件模板查询
+		rows, err := engine.Query("user", "byUsername", map[string]interface{}{"username": "test"})
+		So(err, ShouldBeNil)
+		So(len(rows), ShouldEqual, 1)
+		So(rows[0]["id"], ShouldEqual, int64(1))
+		So(rows[0]["nickname"], ShouldEqual, "测试用户")
+
+		// sql查询，使用默认参数
+		rows, err = engine.Query("user", "countByGender", nil)
+		So(err, ShouldBeNil)
+		So(len(rows), ShouldEqual, 1)
+		So(rows[0]["gender"], ShouldEqual, "保密")
+
+		// 缺少必填参数
+		_, err = engine.Query("user", "byUsername", nil)
+		So(err, ShouldNotBeNil)
+
+		// 查询不存在
+		_, err = engine.Query("user", "notfound", nil)
+		So(err, ShouldEqual, ErrQueryNotFound)
+	})
+}
+
+func TestEngine_FindEach(t *testing.T) {
+	Convey("逐行查询测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		for i := 1; i <= 3; i++ {
+			_, err = engine.Insert("user", map[string]interface{}{
+				"id":       i,
+				"username": fmt.Sprintf("test%d", i),
+				"password": "123456",
+				"email":    fmt.Sprintf("test%d@test.test", i),
+				"mobile":   fmt.Sprintf("1380013800%d", i),
+			})
+			So(err, ShouldBeNil)
+		}
+
+		// 读取所有行
+		var ids []interface{}
+		err = engine.FindEach("user", map[string]interface{}{"$order_by": "id"}, []string{"id"}, func(row map[string]interface{}) error {
+			ids = append(ids, row["id"])
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(ids, ShouldResemble, []interface{}{int64(1), int64(2), int64(3)})
+
+		// 提前停止
+		count := 0
+		err = engine.FindEach("user", nil, nil, func(row map[string]interface{}) error {
+			count++
+			return ErrStopIteration
+		})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+
+		// 回调返回的错误
+		errExport := errors.New("export failed")
+		err = engine.FindEach("user", nil, nil, func(row map[string]interface{}) error {
+			return errExport
+		})
+		So(err, ShouldEqual, errExport)
+
+		// ctx已取消
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = engine.FindEachContext(ctx, "user", nil, nil, func(row map[string]interface{}) error {
+			return nil
+		})
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+	})
+}
+
+func TestEngine_FindOne(t *testing.T) {
+	Convey("单条查询测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		_, err = engine.Insert("user", map[string]interface{}{
+			"id":       1,
+			"username": "test",
+			"password": "123456",
+			"email":    "test@test.test",
+			"mobile":   "13800138000",
+		})
+		So(err, ShouldBeNil)
+
+		row, err := engine.FindOne("user", map[string]interface{}{"username": "test"}, []string{"id", "username"})
+		So(err, ShouldBeNil)
+		So(row["id"], ShouldEqual, int64(1))
+
+		row, err = engine.FindByID("user", 1, nil)
+		So(err, ShouldBeNil)
+		So(row["username"], ShouldEqual, "test")
+
+		_, err = engine.FindByID("user", 2, nil)
+		So(err, ShouldEqual, ErrRecordNotFound)
+	})
+}
+
+func TestEngine_SchemaNotRegistered(t *testing.T) {
+	Convey("未注册的模型", t, func() {
+		engine := newTestEngine()
+
+		_, err := engine.Insert("user", map[string]interface{}{"id": 1})
+		So(err, ShouldEqual, ErrSchemaNotRegistered)
+		_, err = engine.Find("user", nil, nil)
+		So(err, ShouldEqual, ErrSchemaNotRegistered)
+		_, err = engine.FindOne("user", nil, nil)
+		So(err, ShouldEqual, ErrSchemaNotRegistered)
+		_, err = engine.FindByID("user", 1, nil)
+		So(err, ShouldEqual, ErrSchemaNotRegistered)
+		_, err = engine.Update("user", map[string]interface{}{"age": 1}, map[string]interface{}{"id": 1})
+		So(err, ShouldEqual, ErrSchemaNotRegistered)
+		_, err = engine.Delete("user", map[string]interface{}{"id": 1})
+		So(err, ShouldEqual, ErrSchemaNotRegistered)
+		So(engine.MigrateTable("user"), ShouldEqual, ErrSchemaNotRegistered)
+		So(engine.DropTable("user"), ShouldEqual, ErrSchemaNotRegistered)
+	})
+}
+
+func TestEngine_Lock(t *testing.T) {
+	Convey("行锁测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		_, err = engine.Insert("user", map[string]interface{}{
+			"id":       1,
+			"username": "test",
+			"password": "123456",
+			"email":    "test@test.test",
+			"mobile":   "13800138000",
+		})
+		So(err, ShouldBeNil)
+
+		// 不在事务中
+		_, err = engine.Find("user", map[string]interface{}{"id": 1, "$lock": "update"}, nil)
+		So(err, ShouldEqual, ErrLockWithoutTx)
+
+		tx, err := engine.DB.Beginx()
+		So(err, ShouldBeNil)
+		defer tx.Rollback()
+
+		row, err := engine.FindOne("user", map[string]interface{}{"id": 1, "$lock": "update"}, []string{"id", "nickname"}, tx)
+		So(err, ShouldBeNil)
+		So(row["id"], ShouldEqual, int64(1))
+
+		count, err := engine.Update("user", map[string]interface{}{"nickname": "已锁定"}, map[string]interface{}{"id": 1}, tx)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(tx.Commit(), ShouldBeNil)
+	})
+}
+
+func TestEngine_Upsert(t *testing.T) {
+	Convey("插入或更新测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		data := map[string]interface{}{
+			"id":       1,
+			"username": "test",
+			"password": "123456",
+			"nickname": "测试用户",
+			"email":    "test@test.test",
+			"mobile":   "13800138000",
+		}
+		_, err = engine.Upsert("user", []map[string]interface{}{data}, nil, nil)
+		So(err, ShouldBeNil)
+
+		// 主键冲突时更新
+		data["nickname"] = "新昵称"
+		_, err = engine.Upsert("user", []map[string]interface{}{data}, nil, []string{"nickname"})
+		So(err, ShouldBeNil)
+
+		row, err := engine.FindByID("user", 1, nil)
+		So(err, ShouldBeNil)
+		So(row["nickname"], ShouldEqual, "新昵称")
+	})
+}
+
+func TestEngine_Returning(t *testing.T) {
+	Convey("返回写入的数据测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		rows, err := engine.InsertReturning("user", []map[string]interface{}{
+			{"id": 2, "username": "b", "password": "123456", "email": "b@test.test", "mobile": "13800138002"},
+			{"id": 1, "username": "a", "password": "123456", "email": "a@test.test", "mobile": "13800138001"},
+		})
+		So(err, ShouldBeNil)
+		So(len(rows), ShouldEqual, 2)
+		So(rows[0]["id"], ShouldEqual, int64(2))
+		So(rows[1]["username"], ShouldEqual, "a")
+
+		// 更新的条件字段被修改后仍返回更新的行
+		rows, err = engine.UpdateReturning("user", map[string]interface{}{"nickname": "新昵称"}, map[string]interface{}{"nickname": nil})
+		So(err, ShouldBeNil)
+		So(len(rows), ShouldEqual, 2)
+		So(rows[0]["nickname"], ShouldEqual, "新昵称")
+	})
+}
+
+func TestEngine_Version(t *testing.T) {
+	Convey("乐观锁测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		_, err = engine.Register(docDef)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("doc")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("doc")
+
+		_, err = engine.Insert("doc", map[string]interface{}{"id": 1, "title": "a"})
+		So(err, ShouldBeNil)
+
+		// 两人读取同一版本后先后修改，后修改的返回ErrStaleRecord
+		count, err := engine.Update("doc", map[string]interface{}{"title": "b", "lockVersion": 1}, map[string]interface{}{"id": 1})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		_, err = engine.Update("doc", map[string]interface{}{"title": "c", "lockVersion": 1}, map[string]interface{}{"id": 1})
+		So(err, ShouldEqual, ErrStaleRecord)
+
+		row, err := engine.FindByID("doc", 1, nil)
+		So(err, ShouldBeNil)
+		So(row["title"], ShouldEqual, "b")
+		So(row["lock_version"], ShouldEqual, int64(2))
+	})
+}
+
+func TestEngine_BulkInsert(t *testing.T) {
+	Convey("批量插入测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// 注册模型
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		var data []map[string]interface{}
+		for i := 1; i <= 10; i++ {
+			data = append(data, map[string]interface{}{
+				"id":       i,
+				"username": fmt.Sprintf("test%d", i),
+				"password": "123456",
+				"email":    fmt.Sprintf("test%d@test.test", i),
+				"mobile":   fmt.Sprintf("138001380%02d", i),
+			})
+		}
+		results, err := engine.BulkInsert("user", data, BulkInsertOptions{ChunkSize: 4, Atomic: true})
+		So(err, ShouldBeNil)
+		So(len(results), ShouldEqual, 3)
+		So(results[2].RowsAffected, ShouldEqual, 2)
+
+		// 重复的数据，事务回滚
+		data = append(data[8:], map[string]interface{}{
+			"id":       11,
+			"username": "test11",
+			"password": "123456",
+			"email":    "test11@test.test",
+			"mobile":   "13800138011",
+		})
+		results, err = engine.BulkInsert("user", data, BulkInsertOptions{ChunkSize: 1, Atomic: true})
+		So(err, ShouldNotBeNil)
+		So(len(results), ShouldEqual, 1)
+		_, err = engine.FindByID("user", 11, nil)
+		So(err, ShouldEqual, ErrRecordNotFound)
+
+		// 各批独立执行，失败后继续
+		results, err = engine.BulkInsert("user", data, BulkInsertOptions{ChunkSize: 1})
+		So(err, ShouldNotBeNil)
+		So(len(results), ShouldEqual, 3)
+		So(results[2].Err, ShouldBeNil)
+		_, err = engine.FindByID("user", 11, nil)
+		So(err, ShouldBeNil)
+	})
+}
+
+func TestEngine_BulkUpdate(t *testing.T) {
+	Convey("批量更新测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("user")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("user")
+
+		var data, updates []map[string]interface{}
+		for i := 1; i <= 5; i++ {
+			data = append(data, map[string]interface{}{
+				"id":       i,
+				"username": fmt.Sprintf("test%d", i),
+				"password": "123456",
+				"email":    fmt.Sprintf("test%d@test.test", i),
+				"mobile":   fmt.Sprintf("138001380%02d", i),
+			})
+			updates = append(updates, map[string]interface{}{"id": i, "nickname": fmt.Sprintf("昵称%d", i)})
+		}
+		_, err = engine.BulkInsert("user", data, BulkInsertOptions{})
+		So(err, ShouldBeNil)
+
+		count, err := engine.BulkUpdate("user", updates, BulkUpdateOptions{ChunkSize: 2})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 5)
+
+		row, err := engine.FindByID("user", 4, nil)
+		So(err, ShouldBeNil)
+		So(row["nickname"], ShouldEqual, "昵称4")
+	})
+}
+
+func TestEngine_MaxAffected(t *testing.T) {
+	Convey("影响行数限制测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		_, err = engine.Register(roleDef)
+		So(err, ShouldBeNil)
+
+		err = engine.MigrateTable("role")
+		So(err, ShouldBeNil)
+		defer engine.DropTable("role")
+
+		_, err = engine.Insert("role", map[string]interface{}{"id": 1, "name": "a"}, map[string]interface{}{"id": 2, "name": "b"})
+		So(err, ShouldBeNil)
+
+		count, err := engine.DeleteDryRun("role", map[string]interface{}{"$all": true})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+
+		// 超过限制时回滚
+		_, err = engine.Update("role", map[string]interface{}{"name": "c"}, map[string]interface{}{"$all": true, "$max_affected": 1})
+		So(err, ShouldWrap, ErrTooManyAffected)
+		row, err := engine.FindByID("role", 1, nil)
+		So(err, ShouldBeNil)
+		So(row["name"], ShouldEqual, "a")
+
+		count, err = engine.Delete("role", map[string]interface{}{"id": 1, "$max_affected": 1})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+	})
+}
+
+func TestEngine_Cascade(t *testing.T) {
+	Convey("删除时的关联处理测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		for _, d := range []string{roleDef, writerDef, articleDef, draftDef, awardDef} {
+			_, err = engine.Register(d)
+			So(err, ShouldBeNil)
+		}
+		for _, name := range []string{"writer", "article", "draft", "award"} {
+			So(engine.MigrateTable(name), ShouldBeNil)
+			defer engine.DropTable(name)
+		}
+		// 中间表随writer建立
+		defer engine.DB.Exec("DROP TABLE `writer_badge`")
+
+		_, err = engine.Insert("writer", map[string]interface{}{"id": 1, "name": "a"}, map[string]interface{}{"id": 2, "name": "b"})
+		So(err, ShouldBeNil)
+		_, err = engine.Insert("article", map[string]interface{}{"id": 1, "title": "a", "writerId": 1})
+		So(err, ShouldBeNil)
+		_, err = engine.Insert("draft", map[string]interface{}{"id": 1, "writerId": 1})
+		So(err, ShouldBeNil)
+		_, err = engine.Insert("award", map[string]interface{}{"id": 1, "writerId": 2})
+		So(err, ShouldBeNil)
+		_, err = engine.DB.Exec("INSERT INTO `writer_badge` VALUES (1, 1), (2, 1)")
+		So(err, ShouldBeNil)
+
+		// 有奖项的作者不能删除
+		_, err = engine.Delete("writer", map[string]interface{}{"id": 2})
+		So(err, ShouldWrap, ErrDeleteRestricted)
+
+		count, err := engine.Delete("writer", map[string]interface{}{"id": 1})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+
+		rows, err := engine.Find("article", map[string]interface{}{"id": 1}, nil)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		So(rows[0]["deleted_at"], ShouldNotBeNil)
+		draft, err := engine.FindByID("draft", 1, nil)
+		So(err, ShouldBeNil)
+		So(draft["writer_id"], ShouldBeNil)
+		var badges int
+		So(engine.DB.Get(&badges, "SELECT COUNT(*) FROM `writer_badge`"), ShouldBeNil)
+		So(badges, ShouldEqual, 1)
+	})
+}
+
+func TestEngine_Pivot(t *testing.T) {
+	Convey("多对多关联管理测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		for _, d := range []string{roleDef, memberDef, postDef, tagDef} {
+			_, err = engine.Register(d)
+			So(err, ShouldBeNil)
+		}
+		So(engine.MigrateTable("member"), ShouldBeNil)
+		defer engine.DropTable("member")
+		defer engine.DB.Exec("DROP TABLE `member_tag`")
+		exists, err := engine.tableExists(context.Background(), "member_tag")
+		So(err, ShouldBeNil)
+		So(exists, ShouldBeTrue)
+
+		count, err := engine.Attach("member", 1, "tags", []interface{}{1, 2, 2}, nil)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		// 已存在的关联被忽略
+		count, err = engine.Attach("member", 1, "tags", []interface{}{"2", 3}, nil)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+
+		result, err := engine.Sync("member", 1, "tags", []interface{}{3, 4}, nil)
+		So(err, ShouldBeNil)
+		So(result.Attached, ShouldResemble, []interface{}{int64(4)})
+		So(result.Detached, ShouldResemble, []interface{}{int64(1), int64(2)})
+
+		count, err = engine.Detach("member", 1, "tags", []interface{}{3})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		count, err = engine.Detach("member", 1, "tags", nil)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+	})
+}
+
+func TestEngine_Graph(t *testing.T) {
+	Convey("关联数据写入测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		for _, d := range []string{tagDef, orderDef, orderItemDef, invoiceDef} {
+			_, err = engine.Register(d)
+			So(err, ShouldBeNil)
+		}
+		for _, name := range []string{"order", "orderItem", "invoice"} {
+			So(engine.MigrateTable(name), ShouldBeNil)
+			defer engine.DropTable(name)
+		}
+		defer engine.DB.Exec("DROP TABLE `order_tag`")
+
+		ids, err := engine.InsertGraph("order", []map[string]interface{}{{
+			"no":      "A001",
+			"items":   []map[string]interface{}{{"sku": "x", "qty": 1}, {"sku": "y", "qty": 2}},
+			"invoice": map[string]interface{}{"title": "t"},
+		}})
+		So(err, ShouldBeNil)
+		So(ids, ShouldHaveLength, 1)
+		items, err := engine.Find("orderItem", map[string]interface{}{"order_id": ids[0], "$order_by": "sku"}, nil)
+		So(err, ShouldBeNil)
+		So(items, ShouldHaveLength, 2)
+
+		// 子数据失败时全部回滚
+		_, err = engine.InsertGraph("order", []map[string]interface{}{{"no": "A002", "items": []map[string]interface{}{{"qty": "many"}}}})
+		So(err, ShouldNotBeNil)
+		count, err := engine.DeleteDryRun("order", map[string]interface{}{"no": "A002"})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+
+		err = engine.UpdateGraph("order", ids[0], map[string]interface{}{
+			"no":    "A003",
+			"items": []map[string]interface{}{{"id": items[0]["id"], "qty": 5}, {"sku": "z", "qty": 1}},
+		}, GRAPH_REPLACE)
+		So(err, ShouldBeNil)
+		items, err = engine.Find("orderItem", map[string]interface{}{"order_id": ids[0], "$order_by": "sku"}, nil)
+		So(err, ShouldBeNil)
+		So(items, ShouldHaveLength, 2)
+		So(items[0]["qty"], ShouldEqual, 5)
+		So(items[1]["sku"], ShouldEqual, "z")
+	})
+}
+
+func TestEngine_Context(t *testing.T) {
+	Convey("context测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		_, err = engine.Register(roleDef)
+		So(err, ShouldBeNil)
+		So(engine.MigrateTableContext(context.Background(), "role"), ShouldBeNil)
+		defer engine.DropTable("role")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = engine.FindContext(ctx, "role", map[string]interface{}{"id": 1}, nil)
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		_, err = engine.InsertContext(ctx, "role", map[string]interface{}{"id": 1, "name": "a"})
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+
+		// 超时后事务回滚
+		ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		time.Sleep(2 * time.Millisecond)
+		_, err = engine.BulkUpdateContext(ctx, "role", []map[string]interface{}{{"id": 1, "name": "b"}}, BulkUpdateOptions{})
+		So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+	})
+}
+
+func TestEngine_Transaction(t *testing.T) {
+	Convey("事务测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		_, err = engine.Register(roleDef)
+		So(err, ShouldBeNil)
+		So(engine.MigrateTable("role"), ShouldBeNil)
+		defer engine.DropTable("role")
+		ctx := context.Background()
+
+		// 返回错误时全部回滚
+		errFailed := errors.New("failed")
+		err = engine.Transaction(ctx, func(s *Session) error {
+			if _, err := s.Insert("role", map[string]interface{}{"id": 1, "name": "a"}); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		So(err, ShouldEqual, errFailed)
+		count, err := engine.DeleteDryRun("role", map[string]interface{}{"$all": true})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+
+		// panic时回滚后继续panic
+		So(func() {
+			_ = engine.Transaction(ctx, func(s *Session) error {
+				_, _ = s.Insert("role", map[string]interface{}{"id": 1, "name": "a"})
+				panic("boom")
+			})
+		}, ShouldPanicWith, "boom")
+		count, err = engine.DeleteDryRun("role", map[string]interface{}{"$all": true})
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+
+		// 嵌套事务失败只回滚到保存点
+		err = engine.Transaction(ctx, func(s *Session) error {
+			if _, err := s.Insert("role", map[string]interface{}{"id": 1, "name": "a"}); err != nil {
+				return err
+			}
+			err := s.Transaction(func(s *Session) error {
+				if _, err := s.Update("role", map[string]interface{}{"name": "b"}, map[string]interface{}{"id": 1}); err != nil {
+					return err
+				}
+				return errFailed
+			})
+			So(err, ShouldEqual, errFailed)
+			return s.Transaction(func(s *Session) error {
+				_, err := s.Insert("role", map[string]interface{}{"id": 2, "name": "c"})
+				return err
+			})
+		})
+		So(err, ShouldBeNil)
+		rows, err := engine.Find("role", map[string]interface{}{"$order_by": "id"}, nil)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 2)
+		So(rows[0]["name"], ShouldEqual, "a")
+		So(rows[1]["name"], ShouldEqual, "c")
+	})
+}
+
+func TestEngine_Migrate(t *testing.T) {
+	Convey("迁移报告测试", t, func() {
+		engine, err := NewEngine("mysql", "root:root@/lowcode?charset=utf8mb4&parseTime=True&loc=Local")
+		So(err, ShouldBeNil)
+		So(engine, ShouldNotBeNil)
+
+		// tag未注册，member的中间表无法建立
+		for _, d := range []string{roleDef, memberDef, postDef} {
+			_, err = engine.Register(d)
+			So(err, ShouldBeNil)
+		}
+		defer engine.DropTable("role")
+		defer engine.DropTable("member")
+		defer engine.DropTable("post")
+		defer engine.DB.Exec("DROP TABLE `member_tag`")
+
+		report, err := engine.Migrate(MigrateOptions{ContinueOnError: true})
+		So(err, ShouldWrap, ErrMigrateFailed)
+		So(report.Cycles, ShouldBeEmpty)
+		So(report.Results[0].Model, ShouldEqual, "role")
+		statuses := make(map[string]string)
+		for _, result := range report.Results {
+			statuses[result.Model] = result.Status
+		}
+		So(statuses, ShouldResemble, map[string]string{"member": MIGRATE_FAILED, "post": MIGRATE_CREATED, "role": MIGRATE_CREATED})
+
+		// 注册tag后再次迁移，继续建立中间表
+		_, err = engine.Register(tagDef)
+		So(err, ShouldBeNil)
+		defer engine.DropTable("tag")
+		report, err = engine.Migrate(MigrateOptions{})
+		So(err, ShouldBeNil)
+		for _, result := range report.Results {
+			statuses[result.Model] = result.Status
+		}
+		So(statuses, ShouldResemble, map[string]string{"member": MIGRATE_ALTERED, "post": MIGRATE_UNCHANGED, "role": MIGRATE_UNCHANGED, "tag": MIGRATE_CREATED})
+	})
+}
@@ -0,0 +1,6 @@
+package db
+
+import "github.com/yaochi-tech/lingquan-core-go/db/dialect"
+
+// Expr sql表达式，作为Update数据的值时直接使用，如：db.Expr{SQL: "price * ?", Args: []interface{}{1.1}}
+type Expr = dialect.Expr
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDB 记录执行的语句，查询结果由query返回，用于在没有数据库时测试引擎执行的语句及顺序
+// 事务的开始、提交及回滚记录为BEGIN、COMMIT、ROLLBACK
+type fakeDB struct {
+	mu    sync.Mutex
+	log   []string
+	query func(sql string, args []driver.NamedValue) *fakeRows // 返回nil时为空结果
+	exec  func(sql string) (int64, error)                      // 返回影响的行数，为nil时影响1行
+}
+
+// fakeRows 查询结果
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// newFakeEngine 创建使用fakeDB的mysql引擎，并注册模型
+func newFakeEngine(definitions ...string) (*Engine, *fakeDB) {
+	engine := newTestEngine(definitions...)
+	fake := &fakeDB{}
+	engine.DB = sqlx.NewDb(sql.OpenDB(fake), "mysql")
+	engine.currentDatabase = "test"
+	return engine, fake
+}
+
+// statements 返回执行过的语句并清空记录
+func (f *fakeDB) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	log := f.log
+	f.log = nil
+	return log
+}
+
+func (f *fakeDB) record(sql string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, sql)
+}
+
+// 以下实现database/sql/driver的接口
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return &fakeConn{db: f}, nil }
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { c.db.record("BEGIN"); return c, nil }
+func (c *fakeConn) Commit() error                       { c.db.record("COMMIT"); return nil }
+func (c *fakeConn) Rollback() error                     { c.db.record("ROLLBACK"); return nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	affected := int64(1)
+	if c.db.exec != nil {
+		var err error
+		if affected, err = c.db.exec(query); err != nil {
+			return nil, err
+		}
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	var rows *fakeRows
+	if c.db.query != nil {
+		rows = c.db.query(query, args)
+	}
+	if rows == nil {
+		rows = &fakeRows{}
+	}
+	return &fakeResult{rows: rows}, nil
+}
+
+type fakeResult struct {
+	rows *fakeRows
+	next int
+}
+
+func (r *fakeResult) Columns() []string {
+	if len(r.rows.columns) == 0 {
+		return []string{"name"}
+	}
+	return r.rows.columns
+}
+
+func (r *fakeResult) Close() error { return nil }
+
+func (r *fakeResult) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
+
+// writes 过滤出写入的语句及事务语句，去掉查询
+func writes(statements []string) []string {
+	var res []string
+	for _, s := range statements {
+		if !strings.HasPrefix(s, "SELECT") {
+			res = append(res, s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+// UpdateGraph 更新关联数据的方式
+const (
+	GRAPH_MERGE   = "merge"   // 有主键且属于本条数据的关联数据更新，其他的插入，未提及的保留
+	GRAPH_REPLACE = "replace" // 同merge，并删除未提及的关联数据（按关联模型的onDelete处理其关联数据）
+)
+
+// InsertGraph 插入数据及hasOne/hasMany关联数据，关联数据以关系名称为key，hasOne为对象，hasMany为数组
+// 关联数据中本模型id的列自动填充为插入的主键，关联数据可以继续嵌套；所有数据在一个事务中插入，失败时全部回滚
+// 返回本模型各行的主键，有关联数据的行需要传入主键或配置主键生成器，如：
+// engine.InsertGraph("order", []map[string]interface{}{{"no": "A001", "items": []map[string]interface{}{{"sku": "x", "qty": 1}}}})
+func (engine *Engine) InsertGraph(name string, data []map[string]interface{}, tx ...*sqlx.Tx) ([]interface{}, error) {
+	return engine.InsertGraphContext(context.Background(), name, data, tx...)
+}
+
+// InsertGraphContext 同InsertGraph，ctx用于取消及超时
+func (engine *Engine) InsertGraphContext(ctx context.Context, name string, data []map[string]interface{}, tx ...*sqlx.Tx) ([]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	var ids []interface{}
+	err = engine.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		ids, err = engine.insertGraph(ctx, s, data, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// UpdateGraph 按主键更新一条数据及其hasOne/hasMany关联数据，mode为GRAPH_MERGE或GRAPH_REPLACE，在一个事务中执行
+// 关联数据有主键且属于本条数据时更新，否则插入并填充本模型id的列；关联数据可以继续嵌套，按相同的mode处理
+// 主键对应的数据不存在时返回ErrRecordNotFound
+func (engine *Engine) UpdateGraph(name string, id interface{}, data map[string]interface{}, mode string, tx ...*sqlx.Tx) error {
+	return engine.UpdateGraphContext(context.Background(), name, id, data, mode, tx...)
+}
+
+// UpdateGraphContext 同UpdateGraph，ctx用于取消及超时
+func (engine *Engine) UpdateGraphContext(ctx context.Context, name string, id interface{}, data map[string]interface{}, mode string, tx ...*sqlx.Tx) error {
+	if mode != GRAPH_MERGE && mode != GRAPH_REPLACE {
+		return fmt.Errorf("%w: unknown graph mode %q", ErrInvalidGraph, mode)
+	}
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return err
+	}
+	pk := s.PrimaryKey()
+	if pk == nil {
+		return fmt.Errorf("%w: model %s has no primary key", ErrInvalidGraph, s.Name)
+	}
+	return engine.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		// 先锁定本条数据，只有关联数据时不会更新本表，不能通过更新的行数判断数据是否存在
+		_, err := engine.FindOneContext(ctx, s.Name, map[string]interface{}{pk.Column: id, dialect.OP_LOCK: dialect.LOCK_UPDATE}, []string{pk.Name}, tx)
+		if errors.Is(err, ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s %v", ErrRecordNotFound, s.Name, id)
+		}
+		if err != nil {
+			return err
+		}
+		return engine.updateGraph(ctx, s, id, data, mode, tx)
+	})
+}
+
+func (engine *Engine) insertGraph(ctx context.Context, s *schema.Schema, data []map[string]interface{}, tx *sqlx.Tx) ([]interface{}, error) {
+	rows := make([]map[string]interface{}, len(data))
+	nested := make([]map[*schema.Relation][]map[string]interface{}, len(data))
+	for i, row := range data {
+		var err error
+		if rows[i], nested[i], err = splitNested(s, row); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	sql, args, ids, err := engine.buildInsert(s, rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = engine.exec(ctx, sql, args, tx); err != nil {
+		return nil, err
+	}
+
+	// 同一关系的关联数据合并插入
+	for _, relation := range s.Relations {
+		related, children, err := engine.nestedRows(relation, ids, nested)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			continue
+		}
+		if _, err = engine.insertGraph(ctx, related, children, tx); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// nestedRows 各行中关系的关联数据，填充本模型id的列
+func (engine *Engine) nestedRows(relation *schema.Relation, ids []interface{}, nested []map[*schema.Relation][]map[string]interface{}) (*schema.Schema, []map[string]interface{}, error) {
+	var children []map[string]interface{}
+	for i := range nested {
+		if len(nested[i][relation]) > 0 && ids[i] == nil {
+			return nil, nil, fmt.Errorf("%w: row %d has nested %s but no primary key", ErrInvalidGraph, i, relation.Name)
+		}
+		for _, child := range nested[i][relation] {
+			children = append(children, withForeignKey(child, relation.Field, ids[i]))
+		}
+	}
+	if len(children) == 0 {
+		return nil, nil, nil
+	}
+	related, err := engine.schemaOf(relation.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	if related.GetFieldByColumn(relation.Field) == nil {
+		return nil, nil, fmt.Errorf("%w: relation %s field %s not found in model %s", schema.ErrInvalidRelation, relation.Name, relation.Field, related.Name)
+	}
+	return related, children, nil
+}
+
+// updateGraph 按主键更新一条已存在的数据及其关联数据，调用者已检查模型有主键
+func (engine *Engine) updateGraph(ctx context.Context, s *schema.Schema, id interface{}, data map[string]interface{}, mode string, tx *sqlx.Tx) error {
+	pk := s.PrimaryKey()
+	row, nested, err := splitNested(s, data)
+	if err != nil {
+		return err
+	}
+	if key, _ := fieldValue(pk, row); key != "" {
+		delete(row, key)
+	}
+	if len(row) > 0 {
+		if _, err = engine.UpdateContext(ctx, s.Name, row, map[string]interface{}{pk.Column: id}, tx); err != nil {
+			return err
+		}
+	}
+
+	for _, relation := range s.Relations {
+		children, ok := nested[relation]
+		if !ok {
+			continue
+		}
+		if err = engine.updateChildren(ctx, relation, id, children, mode, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateChildren 更新一个关系的关联数据，id为本模型的主键
+func (engine *Engine) updateChildren(ctx context.Context, relation *schema.Relation, id interface{}, children []map[string]interface{}, mode string, tx *sqlx.Tx) error {
+	related, err := engine.schemaOf(relation.Model)
+	if err != nil {
+		return err
+	}
+	pk := related.PrimaryKey()
+	if pk == nil || related.GetFieldByColumn(relation.Field) == nil {
+		return fmt.Errorf("%w: relation %s requires a primary key and field %s in model %s", ErrInvalidGraph, relation.Name, relation.Field, related.Name)
+	}
+	found, err := engine.FindContext(ctx, related.Name, map[string]interface{}{relation.Field: id}, []string{pk.Name}, tx)
+	if err != nil {
+		return err
+	}
+	// 查询结果的主键已按字段类型解码，与传入的主键类型可能不同，按字符串匹配
+	existing := make(map[string]interface{}, len(found))
+	for _, row := range found {
+		existing[fmt.Sprint(row[pk.Column])] = row[pk.Column]
+	}
+
+	kept := make(map[string]bool, len(children))
+	var inserts []map[string]interface{}
+	for _, child := range children {
+		_, childID := fieldValue(pk, child)
+		if childID != nil {
+			if childID, err = schema.Convert(pk.ValueType(), childID); err != nil {
+				return err
+			}
+		} else if relation.Type == schema.RELATION_HAS_ONE && len(existing) == 1 {
+			// hasOne的关联数据没有主键时更新已有的一条
+			for _, existingID := range existing {
+				childID = existingID
+			}
+		}
+		if key := fmt.Sprint(childID); childID != nil && existing[key] != nil {
+			kept[key] = true
+			if err = engine.updateGraph(ctx, related, childID, withForeignKey(child, relation.Field, id), mode, tx); err != nil {
+				return err
+			}
+			continue
+		}
+		inserts = append(inserts, withForeignKey(child, relation.Field, id))
+	}
+	if len(inserts) > 0 {
+		if _, err = engine.insertGraph(ctx, related, inserts, tx); err != nil {
+			return err
+		}
+	}
+
+	if mode != GRAPH_REPLACE {
+		return nil
+	}
+	var removed []interface{}
+	for key, childID := range existing {
+		if !kept[key] {
+			removed = append(removed, childID)
+		}
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	_, err = engine.DeleteContext(ctx, related.Name, map[string]interface{}{pk.Column + " in": removed}, tx)
+	return err
+}
+
+// splitNested 将一行数据分为本模型的字段及各关系的关联数据，返回的数据为新的map，不修改传入的数据
+// key为关系名称且不是本模型字段时作为关联数据，只支持hasOne/hasMany关系
+func splitNested(s *schema.Schema, data map[string]interface{}) (map[string]interface{}, map[*schema.Relation][]map[string]interface{}, error) {
+	row := make(map[string]interface{}, len(data))
+	nested := make(map[*schema.Relation][]map[string]interface{})
+	for k, v := range data {
+		relation := s.GetRelation(k)
+		if relation == nil || s.GetField(k) != nil || s.GetFieldByColumn(k) != nil {
+			row[k] = v
+			continue
+		}
+		if relation.Type != schema.RELATION_HAS_ONE && relation.Type != schema.RELATION_HAS_MANY {
+			return nil, nil, fmt.Errorf("%w: nested %s of type %s is not supported", ErrInvalidGraph, relation.Name, relation.Type)
+		}
+		children, err := nestedChildren(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: nested %s: %v", ErrInvalidGraph, relation.Name, err)
+		}
+		if relation.Type == schema.RELATION_HAS_ONE && len(children) > 1 {
+			return nil, nil, fmt.Errorf("%w: nested %s is hasOne but has %d rows", ErrInvalidGraph, relation.Name, len(children))
+		}
+		nested[relation] = children
+	}
+	return row, nested, nil
+}
+
+// nestedChildren 关联数据为对象或对象数组，json解析的数组为[]interface{}
+func nestedChildren(v interface{}) ([]map[string]interface{}, error) {
+	switch children := v.(type) {
+	case nil:
+		return []map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return []map[string]interface{}{children}, nil
+	case []map[string]interface{}:
+		return children, nil
+	case []interface{}:
+		rows := make([]map[string]interface{}, len(children))
+		for i, child := range children {
+			row, ok := child.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("row %d is %T, want an object", i, child)
+			}
+			rows[i] = row
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("%T is not an object or array", v)
+}
+
+// withForeignKey 复制关联数据并将本模型id的列设置为id，覆盖以字段名或列名传入的值
+func withForeignKey(child map[string]interface{}, column string, id interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(child)+1)
+	for k, v := range child {
+		if util.ToSnake(k) != column {
+			row[k] = v
+		}
+	}
+	row[column] = id
+	return row
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+const orderDef = `{
+  "code": "order",
+  "name": "订单",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID", "generator": "snowflake"},
+    {"label": "编号", "name": "no", "type": "string"}
+  ],
+  "relations": [
+    {"name": "items", "type": "hasMany", "model": "orderItem", "field": "order_id", "onDelete": "cascade"},
+    {"name": "invoice", "type": "hasOne", "model": "invoice", "field": "order_id"},
+    {"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "order_tag", "foreign_key": "tag_id", "local_key": "order_id"}}
+  ]
+}`
+
+const orderItemDef = `{
+  "code": "orderItem",
+  "name": "订单明细",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID", "generator": "snowflake"},
+    {"label": "订单", "name": "orderId", "type": "int64"},
+    {"label": "商品", "name": "sku", "type": "string"},
+    {"label": "数量", "name": "qty", "type": "int"}
+  ]
+}`
+
+const invoiceDef = `{
+  "code": "invoice",
+  "name": "发票",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID", "generator": "snowflake"},
+    {"label": "订单", "name": "orderId", "type": "int64"},
+    {"label": "抬头", "name": "title", "type": "string"}
+  ]
+}`
+
+func TestEngine_GraphSplit(t *testing.T) {
+	Convey("关联数据的拆分", t, func() {
+		engine := newTestEngine(tagDef, orderDef, orderItemDef, invoiceDef)
+		s := engine.GetSchema("order")
+
+		Convey("按关系名称拆分", func() {
+			row, nested, err := splitNested(s, map[string]interface{}{
+				"no":      "A001",
+				"items":   []interface{}{map[string]interface{}{"sku": "x"}, map[string]interface{}{"sku": "y"}},
+				"invoice": map[string]interface{}{"title": "t"},
+			})
+			So(err, ShouldBeNil)
+			So(row, ShouldResemble, map[string]interface{}{"no": "A001"})
+			So(nested[s.GetRelation("items")], ShouldHaveLength, 2)
+			So(nested[s.GetRelation("invoice")], ShouldResemble, []map[string]interface{}{{"title": "t"}})
+		})
+
+		Convey("不支持的关联数据", func() {
+			_, _, err := splitNested(s, map[string]interface{}{"tags": []interface{}{map[string]interface{}{"id": 1}}})
+			So(errors.Is(err, ErrInvalidGraph), ShouldBeTrue)
+			_, _, err = splitNested(s, map[string]interface{}{"invoice": []interface{}{map[string]interface{}{}, map[string]interface{}{}}})
+			So(errors.Is(err, ErrInvalidGraph), ShouldBeTrue)
+			_, _, err = splitNested(s, map[string]interface{}{"items": []interface{}{1}})
+			So(errors.Is(err, ErrInvalidGraph), ShouldBeTrue)
+		})
+
+		Convey("填充本模型id的列", func() {
+			child := map[string]interface{}{"sku": "x", "orderId": 9}
+			So(withForeignKey(child, "order_id", int64(1)), ShouldResemble, map[string]interface{}{"sku": "x", "order_id": int64(1)})
+			So(child["orderId"], ShouldEqual, 9)
+		})
+
+		Convey("离线引擎及错误的参数", func() {
+			_, err := engine.InsertGraph("order", []map[string]interface{}{{"no": "A001"}})
+			So(err, ShouldEqual, ErrEngineOffline)
+			err = engine.UpdateGraph("order", 1, map[string]interface{}{"no": "A002"}, "append")
+			So(errors.Is(err, ErrInvalidGraph), ShouldBeTrue)
+		})
+	})
+}
+
+func TestEngine_GraphStatements(t *testing.T) {
+	Convey("关联数据的写入", t, func() {
+		engine, fake := newFakeEngine(tagDef, orderDef, orderItemDef, invoiceDef)
+
+		Convey("同一关系的关联数据合并插入", func() {
+			ids, err := engine.InsertGraph("order", []map[string]interface{}{
+				{"id": 1, "no": "A001", "items": []interface{}{map[string]interface{}{"id": 11, "sku": "x", "qty": 1}}, "invoice": map[string]interface{}{"id": 21, "title": "t"}},
+				{"id": 2, "no": "A002", "items": []map[string]interface{}{{"id": 12, "sku": "y", "orderId": 9}}},
+			})
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []interface{}{int64(1), int64(2)})
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"INSERT INTO `order` (`id`, `no`) VALUES (1, 'A001'), (2, 'A002')",
+				"INSERT INTO `order_item` (`id`, `order_id`, `qty`, `sku`) VALUES (11, 1, 1, 'x'), (12, 2, NULL, 'y')",
+				"INSERT INTO `invoice` (`id`, `order_id`, `title`) VALUES (21, 1, 't')",
+				"COMMIT",
+			})
+		})
+
+		Convey("关联数据插入失败时全部回滚", func() {
+			fake.exec = func(sql string) (int64, error) {
+				if strings.HasPrefix(sql, "INSERT INTO `order_item`") {
+					return 0, errors.New("duplicate")
+				}
+				return 1, nil
+			}
+			_, err := engine.InsertGraph("order", []map[string]interface{}{{"id": 1, "items": []interface{}{map[string]interface{}{"id": 11}}}})
+			So(err, ShouldNotBeNil)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"INSERT INTO `order` (`id`) VALUES (1)",
+				"INSERT INTO `order_item` (`id`, `order_id`) VALUES (11, 1)",
+				"ROLLBACK",
+			})
+		})
+
+		Convey("按mode更新关联数据", func() {
+			// 订单1已有明细11、13及发票21
+			fake.query = func(sql string, args []driver.NamedValue) *fakeRows {
+				if strings.Contains(sql, "FROM `order_item`") {
+					return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(11)}, {int64(13)}}}
+				}
+				if strings.Contains(sql, "FROM `invoice`") {
+					return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(21)}}}
+				}
+				if strings.Contains(sql, "FROM `order`") && strings.Contains(sql, "(`id` = 1)") {
+					return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(1)}}}
+				}
+				return nil
+			}
+			data := map[string]interface{}{
+				"no":      "B",
+				"items":   []interface{}{map[string]interface{}{"id": "11", "qty": 2}, map[string]interface{}{"id": 14, "sku": "z"}},
+				"invoice": map[string]interface{}{"title": "u"},
+			}
+
+			Convey("merge保留未提及的关联数据", func() {
+				So(engine.UpdateGraph("order", 1, data, GRAPH_MERGE), ShouldBeNil)
+				So(writes(fake.statements()), ShouldResemble, []string{
+					"BEGIN",
+					"UPDATE `order` SET `no`='B' WHERE (`id` = 1)",
+					"UPDATE `order_item` SET `order_id`=1,`qty`=2 WHERE (`id` = 11)",
+					"INSERT INTO `order_item` (`id`, `order_id`, `sku`) VALUES (14, 1, 'z')",
+					"UPDATE `invoice` SET `order_id`=1,`title`='u' WHERE (`id` = 21)",
+					"COMMIT",
+				})
+			})
+
+			Convey("replace删除未提及的关联数据", func() {
+				So(engine.UpdateGraph("order", 1, data, GRAPH_REPLACE), ShouldBeNil)
+				So(writes(fake.statements()), ShouldResemble, []string{
+					"BEGIN",
+					"UPDATE `order` SET `no`='B' WHERE (`id` = 1)",
+					"UPDATE `order_item` SET `order_id`=1,`qty`=2 WHERE (`id` = 11)",
+					"INSERT INTO `order_item` (`id`, `order_id`, `sku`) VALUES (14, 1, 'z')",
+					"DELETE `order_item` FROM `order_item` WHERE (`id` IN (13))",
+					"UPDATE `invoice` SET `order_id`=1,`title`='u' WHERE (`id` = 21)",
+					"COMMIT",
+				})
+			})
+
+			Convey("数据不存在时不更新关联数据", func() {
+				err := engine.UpdateGraph("order", 2, map[string]interface{}{"items": []interface{}{map[string]interface{}{"sku": "z"}}}, GRAPH_MERGE)
+				So(err, ShouldWrap, ErrRecordNotFound)
+				So(fake.statements(), ShouldResemble, []string{
+					"BEGIN",
+					"SELECT `id` FROM `order` WHERE (`id` = 2) LIMIT 1 FOR UPDATE ",
+					"ROLLBACK",
+				})
+			})
+
+			Convey("没有提及的关系不处理", func() {
+				So(engine.UpdateGraph("order", 1, map[string]interface{}{"no": "C"}, GRAPH_REPLACE), ShouldBeNil)
+				So(fake.statements(), ShouldResemble, []string{
+					"BEGIN",
+					"SELECT `id` FROM `order` WHERE (`id` = 1) LIMIT 1 FOR UPDATE ",
+					"UPDATE `order` SET `no`='C' WHERE (`id` = 1)",
+					"COMMIT",
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+)
+
+// UpdateDryRun 统计Update将要更新的行数，不会修改数据，条件、版本号及$non_negative同Update
+func (engine *Engine) UpdateDryRun(name string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	return engine.UpdateDryRunContext(context.Background(), name, data, namedCondition, tx...)
+}
+
+// UpdateDryRunContext 同UpdateDryRun，ctx用于取消及超时
+func (engine *Engine) UpdateDryRunContext(ctx context.Context, name string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return 0, err
+	}
+	data, namedCondition, _, err = updateRow(s, data, namedCondition)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkJSONPaths(s, namedCondition); err != nil {
+		return 0, err
+	}
+	return engine.count(ctx, s.TableName, data, namedCondition, tx...)
+}
+
+// DeleteDryRun 统计Delete将要删除的行数，不会修改数据
+func (engine *Engine) DeleteDryRun(name string, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	return engine.DeleteDryRunContext(context.Background(), name, namedCondition, tx...)
+}
+
+// DeleteDryRunContext 同DeleteDryRun，ctx用于取消及超时
+func (engine *Engine) DeleteDryRunContext(ctx context.Context, name string, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkJSONPaths(s, namedCondition); err != nil {
+		return 0, err
+	}
+	return engine.count(ctx, s.TableName, nil, namedCondition, tx...)
+}
+
+func (engine *Engine) count(ctx context.Context, tableName string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	where, err := engine.resolveSubQueries(namedCondition)
+	if err != nil {
+		return 0, err
+	}
+	sql, args, err := engine.dialect.BuildCount(tableName, data, where)
+	if err != nil {
+		return 0, err
+	}
+	ex, err := engine.executor(tx...)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = sqlx.GetContext(ctx, ex, &count, sql, args...)
+	return count, err
+}
+
+// execBounded 执行更新或删除，条件中有$max_affected时在事务中执行，影响的行数超过该值时回滚并返回ErrTooManyAffected
+// 传入事务时不会回滚，由调用者处理返回的错误
+func (engine *Engine) execBounded(ctx context.Context, sql string, args []interface{}, namedCondition map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	limit, ok, err := maxAffected(namedCondition)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return engine.exec(ctx, sql, args, tx...)
+	}
+
+	var ownTx *sqlx.Tx
+	if len(tx) == 0 || tx[0] == nil {
+		if engine.DB == nil {
+			return 0, ErrEngineOffline
+		}
+		if ownTx, err = engine.DB.BeginTxx(ctx, nil); err != nil {
+			return 0, err
+		}
+		tx = []*sqlx.Tx{ownTx}
+	}
+	count, err := engine.exec(ctx, sql, args, tx...)
+	if err == nil && count > limit {
+		err = fmt.Errorf("%w: %d rows affected, limit is %d", ErrTooManyAffected, count, limit)
+	}
+	if ownTx != nil {
+		if err != nil {
+			_ = ownTx.Rollback()
+			return 0, err
+		}
+		if err = ownTx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+	return count, err
+}
+
+// maxAffected 条件中的$max_affected，json解析的数字为float64
+func maxAffected(namedCondition map[string]interface{}) (int64, bool, error) {
+	v, ok := namedCondition[dialect.OP_MAX_AFFECTED]
+	if !ok {
+		return 0, false, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return int64(n), true, nil
+	case int64:
+		return n, true, nil
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n), true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("%w: %s must be an integer, got %v", dialect.ErrInvalidUpdate, dialect.OP_MAX_AFFECTED, v)
+}
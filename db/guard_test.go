@@ -0,0 +1,38 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"testing"
+)
+
+func TestEngine_Guard(t *testing.T) {
+	Convey("更新、删除的保护", t, func() {
+		engine := newTestEngine(roleDef, docDef)
+
+		Convey("没有条件时拒绝", func() {
+			_, _, err := engine.UpdateSQL("role", map[string]interface{}{"name": "a"}, nil)
+			So(err, ShouldEqual, dialect.ErrEmptyWhere)
+			_, _, err = engine.DeleteSQL("role", map[string]interface{}{"$or": map[string]interface{}{}})
+			So(err, ShouldEqual, dialect.ErrEmptyWhere)
+			// 版本号不能代替条件
+			_, _, err = engine.UpdateSQL("doc", map[string]interface{}{"title": "a", "lockVersion": 1}, nil)
+			So(err, ShouldEqual, dialect.ErrEmptyWhere)
+			_, err = engine.UpdateDryRun("role", map[string]interface{}{"name": "a"}, nil)
+			So(err, ShouldEqual, dialect.ErrEmptyWhere)
+		})
+
+		Convey("$all更新所有行", func() {
+			sql, _, err := engine.UpdateSQL("role", map[string]interface{}{"name": "a"}, map[string]interface{}{"$all": true})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "UPDATE `role` SET `name`='a'")
+		})
+
+		Convey("$max_affected必须是整数", func() {
+			_, err := engine.Delete("role", map[string]interface{}{"id": 1, "$max_affected": "10"})
+			So(err, ShouldWrap, dialect.ErrInvalidUpdate)
+			_, err = engine.Delete("role", map[string]interface{}{"id": 1, "$max_affected": float64(10)})
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+	})
+}
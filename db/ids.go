@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/idgen"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+// fillIDs 为缺少主键的行生成主键，返回补齐主键后的数据及各行的主键，主键字段没有配置生成器时原样返回
+// 生成主键的行会复制一份，不修改调用者传入的数据
+func fillIDs(s *schema.Schema, data []map[string]interface{}) ([]map[string]interface{}, []interface{}, error) {
+	pk := s.PrimaryKey()
+	if pk == nil {
+		return data, make([]interface{}, len(data)), nil
+	}
+
+	rows := make([]map[string]interface{}, len(data))
+	ids := make([]interface{}, len(data))
+	for i, row := range data {
+		rows[i] = row
+		key, id := fieldValue(pk, row)
+		if id != nil || pk.Generator == "" {
+			ids[i] = id
+			continue
+		}
+
+		generated, err := idgen.Generate(pk.Generator)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generate %s: %w", pk.Name, err)
+		}
+		filled := make(map[string]interface{}, len(row)+1)
+		for k, v := range row {
+			filled[k] = v
+		}
+		if key == "" {
+			key = pk.Name
+		}
+		filled[key] = generated
+		rows[i], ids[i] = filled, generated
+	}
+	return rows, ids, nil
+}
+
+// fieldValue 行中字段的值及其key，key可以是字段名或列名，没有该字段时key为空
+func fieldValue(field *schema.Field, row map[string]interface{}) (string, interface{}) {
+	for k, v := range row {
+		if k == field.Name || util.ToSnake(k) == field.Column {
+			return k, v
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/idgen"
+	"strings"
+	"testing"
+)
+
+const tokenDef = `{
+  "code": "token",
+  "name": "令牌",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID", "generator": "seqId"},
+    {"label": "名称", "name": "name", "type": "string", "unique": true}
+  ]
+}`
+
+const sessionDef = `{
+  "code": "session",
+  "name": "会话",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID", "generator": "ulid"},
+    {"label": "用户", "name": "userId", "type": "int64"}
+  ]
+}`
+
+func TestEngine_GenerateIDs(t *testing.T) {
+	var next int64
+	idgen.Register("seqId", idgen.GeneratorFunc(idgen.TYPE_INT64, func() (interface{}, error) {
+		next++
+		return next, nil
+	}))
+
+	Convey("插入时生成主键", t, func() {
+		next = 0
+		engine := newTestEngine(tokenDef, sessionDef)
+
+		Convey("只为缺少主键的行生成主键，不修改传入的数据", func() {
+			data := []map[string]interface{}{{"name": "a"}, {"id": 100, "name": "b"}, {"id": nil, "name": "c"}}
+			sql, _, err := engine.InsertSQL("token", data...)
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "INSERT INTO `token` (`id`, `name`) VALUES (1, 'a'), (100, 'b'), (2, 'c')")
+			So(data[0], ShouldResemble, map[string]interface{}{"name": "a"})
+		})
+
+		Convey("批量插入返回各批的主键", func() {
+			chunks, err := engine.BulkInsertSQL("token", []map[string]interface{}{{"name": "a"}, {"name": "b"}, {"name": "c"}}, BulkInsertOptions{ChunkSize: 2})
+			So(err, ShouldBeNil)
+			So(chunks[0].IDs, ShouldResemble, []interface{}{int64(1), int64(2)})
+			So(chunks[1].IDs, ShouldResemble, []interface{}{int64(3)})
+		})
+
+		Convey("upsert按传入的数据判断冲突", func() {
+			sql, _, err := engine.UpsertSQL("token", []map[string]interface{}{{"name": "a"}}, nil, []string{})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "INSERT INTO `token` (`id`, `name`) VALUES (1, 'a') ON DUPLICATE KEY UPDATE `name` = `name`")
+		})
+
+		Convey("字符串主键", func() {
+			sql, _, err := engine.InsertSQL("session", map[string]interface{}{"userId": 1})
+			So(err, ShouldBeNil)
+			So(sql, ShouldStartWith, "INSERT INTO `session` (`id`, `user_id`) VALUES ('")
+
+			sqls, err := engine.MigrateTableSQL("session")
+			So(err, ShouldBeNil)
+			So(strings.Contains(sqls[0], "`id` varchar(36) NOT NULL"), ShouldBeTrue)
+		})
+
+		Convey("离线引擎插入", func() {
+			_, err := engine.Insert("token", map[string]interface{}{"name": "a"})
+			So(err, ShouldEqual, ErrEngineOffline)
+			_, err = engine.InsertReturning("token", []map[string]interface{}{{"name": "a"}})
+			So(err, ShouldEqual, ErrEngineOffline)
+			_, err = engine.UpdateReturning("token", map[string]interface{}{"name": "b"}, map[string]interface{}{"id": 1})
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+	})
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// 模型迁移的结果
+const (
+	MIGRATE_CREATED   = "created"   // 新建了表
+	MIGRATE_ALTERED   = "altered"   // 表已存在，建立了中间表、缺失的索引或外键约束
+	MIGRATE_UNCHANGED = "unchanged" // 表已存在，没有变化
+	MIGRATE_FAILED    = "failed"    // 迁移失败，Err为失败的原因
+	MIGRATE_SKIPPED   = "skipped"   // 之前的模型迁移失败且未设置ContinueOnError，没有迁移
+)
+
+var (
+	// ErrMigrateFailed 有模型迁移失败，各模型的结果在MigrateReport中
+	ErrMigrateFailed error = errors.New("migrate failed")
+)
+
+// MigrateOptions 迁移选项，ContinueOnError为模型迁移失败时是否继续迁移其他模型
+type MigrateOptions struct {
+	ContinueOnError bool
+}
+
+// MigrateResult 一个模型的迁移结果
+type MigrateResult struct {
+	Model  string
+	Table  string
+	Status string
+	Err    error
+}
+
+// MigrateReport 迁移报告，Results按迁移的顺序排列，Cycles为关系中循环依赖的模型
+type MigrateReport struct {
+	Results []*MigrateResult
+	Cycles  [][]string
+}
+
+// Failed 迁移失败的模型
+func (report *MigrateReport) Failed() []*MigrateResult {
+	var failed []*MigrateResult
+	for _, result := range report.Results {
+		if result.Status == MIGRATE_FAILED {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err 有模型迁移失败时返回包装了ErrMigrateFailed的错误，包含各失败模型的原因
+func (report *MigrateReport) Err() error {
+	failed := report.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	messages := make([]string, len(failed))
+	for i, result := range failed {
+		messages[i] = fmt.Sprintf("%s: %v", result.Model, result.Err)
+	}
+	return fmt.Errorf("%w: %s", ErrMigrateFailed, strings.Join(messages, "; "))
+}
+
+// Migrate 迁移所有注册的模型，返回各模型的迁移结果，有模型失败时同时返回ErrMigrateFailed
+// 按MigrateOrder的顺序迁移，被依赖的模型先建立；各模型依次迁移，不在事务中执行（mysql等数据库的DDL会自动提交），迁移可以重复执行：已存在的表、中间表及外键约束会跳过，
+// 部分失败后修正问题再次执行即可继续。所有表建立后再建立外键约束
+func (engine *Engine) Migrate(opts MigrateOptions) (*MigrateReport, error) {
+	return engine.MigrateContext(context.Background(), opts)
+}
+
+// MigrateContext 同Migrate，ctx用于取消及超时
+func (engine *Engine) MigrateContext(ctx context.Context, opts MigrateOptions) (*MigrateReport, error) {
+	if engine.DB == nil {
+		return nil, ErrEngineOffline
+	}
+	schemas := engine.GetSchemas()
+	names, cycles := engine.MigrateOrder()
+
+	report := &MigrateReport{Cycles: cycles}
+	stopped := false
+	fail := func(result *MigrateResult, err error) {
+		result.Status, result.Err = MIGRATE_FAILED, err
+		stopped = !opts.ContinueOnError
+	}
+	for _, name := range names {
+		result := &MigrateResult{Model: name, Table: schemas[name].TableName, Status: MIGRATE_SKIPPED}
+		report.Results = append(report.Results, result)
+		if stopped {
+			continue
+		}
+		status, err := engine.migrateTable(ctx, name)
+		if err != nil {
+			fail(result, err)
+			continue
+		}
+		result.Status = status
+	}
+
+	// 所有表建立后再建立外键约束，约束建立在关联模型的表上，结果记录在本模型
+	for _, result := range report.Results {
+		if stopped {
+			break
+		}
+		if result.Status == MIGRATE_FAILED || result.Status == MIGRATE_SKIPPED {
+			continue
+		}
+		altered, err := engine.migrateForeignKeys(ctx, result.Model)
+		if err != nil {
+			fail(result, err)
+			continue
+		}
+		if altered && result.Status == MIGRATE_UNCHANGED {
+			result.Status = MIGRATE_ALTERED
+		}
+	}
+	return report, report.Err()
+}
+
+// migrateForeignKeys 建立模型关系中不存在的外键约束，返回是否建立了约束
+func (engine *Engine) migrateForeignKeys(ctx context.Context, name string) (bool, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return false, err
+	}
+	keys, err := engine.foreignKeys(s)
+	if err != nil {
+		return false, err
+	}
+	altered := false
+	for _, key := range keys {
+		sql, args := engine.dialect.ForeignKeyExistSQL(key.table, key.name, engine.currentDatabase)
+		if sql == "" {
+			continue
+		}
+		exists, err := engine.exists(ctx, sql, args)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			continue
+		}
+		if _, err = engine.exec(ctx, key.sql, nil); err != nil {
+			return false, fmt.Errorf("foreign key %s: %w", key.name, err)
+		}
+		altered = true
+	}
+	return altered, nil
+}
+
+// MigrateOrder 按模型关系计算迁移顺序，被依赖的模型在前，没有依赖关系的模型按名称排序
+// belongsTo依赖关联模型，hasOne/hasMany的关联模型依赖本模型，manyToMany依赖关联模型（中间表随本模型建立）
+// 有循环依赖时，循环中的模型按名称排序并返回在cycles中；外键约束在所有表建立后添加，循环依赖不影响迁移
+func (engine *Engine) MigrateOrder() (order []string, cycles [][]string) {
+	schemas := engine.GetSchemas()
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// dependencies 模型 => 依赖的模型，只包含已注册的模型
+	dependencies := make(map[string][]string, len(names))
+	depend := func(name, on string) {
+		if _, ok := schemas[on]; ok && name != on {
+			dependencies[name] = append(dependencies[name], on)
+		}
+	}
+	for _, name := range names {
+		for _, relation := range schemas[name].Relations {
+			switch relation.Type {
+			case schema.RELATION_BELONGS_TO, schema.RELATION_MANY_TO_MANY:
+				depend(name, relation.Model)
+			case schema.RELATION_HAS_ONE, schema.RELATION_HAS_MANY:
+				depend(relation.Model, name)
+			}
+		}
+	}
+	for _, name := range names {
+		sort.Strings(dependencies[name])
+	}
+
+	// tarjan强连通分量，分量在其依赖的分量之后产生，即依赖在前
+	index := make(map[string]int, len(names))
+	lowLink := make(map[string]int, len(names))
+	onStack := make(map[string]bool, len(names))
+	var stack []string
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = len(index)
+		lowLink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, on := range dependencies[name] {
+			if _, visited := index[on]; !visited {
+				visit(on)
+				lowLink[name] = min(lowLink[name], lowLink[on])
+			} else if onStack[on] {
+				lowLink[name] = min(lowLink[name], index[on])
+			}
+		}
+		if lowLink[name] != index[name] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == name {
+				break
+			}
+		}
+		sort.Strings(component)
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+		}
+		order = append(order, component...)
+	}
+	for _, name := range names {
+		if _, visited := index[name]; !visited {
+			visit(name)
+		}
+	}
+	return order, cycles
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestMigrateReport(t *testing.T) {
+	Convey("迁移报告", t, func() {
+		report := &MigrateReport{Results: []*MigrateResult{
+			{Model: "role", Table: "role", Status: MIGRATE_CREATED},
+			{Model: "member", Table: "member", Status: MIGRATE_FAILED, Err: ErrSchemaNotRegistered},
+			{Model: "post", Table: "post", Status: MIGRATE_SKIPPED},
+		}}
+		So(report.Failed(), ShouldResemble, []*MigrateResult{report.Results[1]})
+		err := report.Err()
+		So(errors.Is(err, ErrMigrateFailed), ShouldBeTrue)
+		So(strings.Contains(err.Error(), "member: schema not registered"), ShouldBeTrue)
+
+		report.Results[1].Status, report.Results[1].Err = MIGRATE_UNCHANGED, nil
+		So(report.Err(), ShouldBeNil)
+	})
+}
+
+func TestEngine_MigrateOrder(t *testing.T) {
+	Convey("迁移顺序", t, func() {
+		Convey("被依赖的模型在前", func() {
+			engine := newTestEngine(postDef, memberDef, tagDef, roleDef)
+			order, cycles := engine.MigrateOrder()
+			So(order, ShouldResemble, []string{"role", "tag", "member", "post"})
+			So(cycles, ShouldBeEmpty)
+		})
+
+		Convey("循环依赖", func() {
+			engine := newTestEngine(
+				`{"code": "a", "fields": [{"name": "id", "type": "ID"}, {"name": "bId", "type": "int64"}], "relations": [{"name": "b", "type": "belongsTo", "model": "b", "field": "b_id"}]}`,
+				`{"code": "b", "fields": [{"name": "id", "type": "ID"}, {"name": "aId", "type": "int64"}, {"name": "parentId", "type": "int64"}], "relations": [
+					{"name": "a", "type": "belongsTo", "model": "a", "field": "a_id"},
+					{"name": "parent", "type": "belongsTo", "model": "b", "field": "parent_id"}
+				]}`,
+				`{"code": "c", "fields": [{"name": "id", "type": "ID"}], "relations": [{"name": "as", "type": "hasMany", "model": "a", "field": "c_id"}]}`,
+				roleDef,
+			)
+			order, cycles := engine.MigrateOrder()
+			So(order, ShouldResemble, []string{"c", "a", "b", "role"})
+			// 引用自身不是循环依赖
+			So(cycles, ShouldResemble, [][]string{{"a", "b"}})
+		})
+	})
+}
+
+func TestEngine_MigrateIndexes(t *testing.T) {
+	Convey("迁移时补建缺失的索引", t, func() {
+		articleDef := `{"code": "article", "fields": [{"name": "id", "type": "ID"}, {"name": "title", "type": "string", "fulltext": "FTS_TITLE"}, {"name": "authorId", "type": "int64", "index": true}]}`
+		engine, fake := newFakeEngine(articleDef, memberDef, tagDef, roleDef, postDef)
+		tables := map[string]bool{}
+		indexes := map[string]bool{}
+		fake.query = func(sql string, args []driver.NamedValue) *fakeRows {
+			var found bool
+			switch {
+			case strings.Contains(sql, "information_schema.tables"):
+				found = tables[args[0].Value.(string)]
+			case strings.Contains(sql, "information_schema.statistics"):
+				found = indexes[args[1].Value.(string)]
+			}
+			if !found {
+				return nil
+			}
+			return &fakeRows{values: [][]driver.Value{{args[0].Value}}}
+		}
+
+		Convey("表不存在时建表及索引", func() {
+			status, err := engine.migrateTable(context.Background(), "article")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, MIGRATE_CREATED)
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"CREATE TABLE IF NOT EXISTS `article` (`id` bigint NOT NULL,`title` varchar(255),`author_id` bigint, PRIMARY KEY(`id`))",
+				"CREATE INDEX `IDX_ARTICLE_AUTHOR_ID` ON `article` (`author_id`)",
+				"CREATE FULLTEXT INDEX `FTS_TITLE` ON `article` (`title`)",
+			})
+
+			sqls, err := engine.MigrateTableSQL("article")
+			So(err, ShouldBeNil)
+			So(sqls, ShouldResemble, []string{
+				"CREATE TABLE IF NOT EXISTS `article` (`id` bigint NOT NULL,`title` varchar(255),`author_id` bigint, PRIMARY KEY(`id`))",
+				"CREATE INDEX `IDX_ARTICLE_AUTHOR_ID` ON `article` (`author_id`)",
+				"CREATE FULLTEXT INDEX `FTS_TITLE` ON `article` (`title`)",
+			})
+		})
+
+		Convey("表已存在但索引建立失败", func() {
+			// mysql的ddl自动提交，上次迁移建表成功后建立索引失败
+			tables["article"] = true
+			status, err := engine.migrateTable(context.Background(), "article")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, MIGRATE_ALTERED)
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"CREATE INDEX `IDX_ARTICLE_AUTHOR_ID` ON `article` (`author_id`)",
+				"CREATE FULLTEXT INDEX `FTS_TITLE` ON `article` (`title`)",
+			})
+
+			indexes["IDX_ARTICLE_AUTHOR_ID"], indexes["FTS_TITLE"] = true, true
+			status, err = engine.migrateTable(context.Background(), "article")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, MIGRATE_UNCHANGED)
+			So(writes(fake.statements()), ShouldBeEmpty)
+		})
+
+		Convey("表已存在时补建唯一索引", func() {
+			engine, fake := newFakeEngine(`{"code": "account", "fields": [{"name": "id", "type": "ID"}, {"name": "email", "type": "string", "unique": true}]}`)
+			fake.query = func(sql string, args []driver.NamedValue) *fakeRows {
+				if strings.Contains(sql, "information_schema.tables") {
+					return &fakeRows{values: [][]driver.Value{{args[0].Value}}}
+				}
+				return nil
+			}
+			status, err := engine.migrateTable(context.Background(), "account")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, MIGRATE_ALTERED)
+			So(writes(fake.statements()), ShouldResemble, []string{"CREATE UNIQUE INDEX `UNI_ACCOUNT_EMAIL` ON `account` (`email`)"})
+		})
+
+		Convey("中间表已存在但索引建立失败", func() {
+			tables["member"], tables["member_tag"] = true, true
+			indexes["IDX_MEMBER_TAG_MEMBER_ID"] = true
+			status, err := engine.migrateTable(context.Background(), "member")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, MIGRATE_ALTERED)
+			So(writes(fake.statements()), ShouldResemble, []string{"CREATE INDEX `IDX_MEMBER_TAG_TAG_ID` ON `member_tag` (`tag_id`)"})
+		})
+	})
+}
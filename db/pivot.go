@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// SyncResult Sync的结果，Attached为新增关联的id，Detached为解除关联的id
+type SyncResult struct {
+	Attached []interface{}
+	Detached []interface{}
+}
+
+// pivotSchemas 模型的多对多关系中间表的模式，多个关系使用同一个中间表时只返回一次
+func (engine *Engine) pivotSchemas(s *schema.Schema) ([]*schema.Schema, error) {
+	var pivots []*schema.Schema
+	seen := make(map[string]bool)
+	for _, relation := range s.Relations {
+		if relation.Type != schema.RELATION_MANY_TO_MANY || seen[relation.Pivot.Table] {
+			continue
+		}
+		related, err := engine.schemaOf(relation.Model)
+		if err != nil {
+			return nil, err
+		}
+		pivot, err := relation.PivotSchema(s, related)
+		if err != nil {
+			return nil, err
+		}
+		seen[relation.Pivot.Table] = true
+		pivots = append(pivots, pivot)
+	}
+	return pivots, nil
+}
+
+// pivotOf 模型中多对多关系的中间表模式，relationName为关系名称
+func (engine *Engine) pivotOf(name, relationName string) (*schema.Relation, *schema.Schema, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	relation := s.GetRelation(relationName)
+	if relation == nil {
+		return nil, nil, fmt.Errorf("%w: relation %s not found in model %s", schema.ErrInvalidRelation, relationName, name)
+	}
+	related, err := engine.schemaOf(relation.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	pivot, err := relation.PivotSchema(s, related)
+	if err != nil {
+		return nil, nil, err
+	}
+	return relation, pivot, nil
+}
+
+// Attach 在多对多关系的中间表中添加id与ids的关联，已存在的关联被忽略，返回新增的行数
+// extra为中间表中其他列的值，应用于新增的每一行，可以为nil
+func (engine *Engine) Attach(name string, id interface{}, relationName string, ids []interface{}, extra map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	return engine.AttachContext(context.Background(), name, id, relationName, ids, extra, tx...)
+}
+
+// AttachContext 同Attach，ctx用于取消及超时
+func (engine *Engine) AttachContext(ctx context.Context, name string, id interface{}, relationName string, ids []interface{}, extra map[string]interface{}, tx ...*sqlx.Tx) (int64, error) {
+	relation, pivot, err := engine.pivotOf(name, relationName)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = engine.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		localID, existing, err := engine.pivotIDs(ctx, relation, pivot, id, tx)
+		if err != nil {
+			return err
+		}
+		attach, err := pivotDiff(pivot, relation, ids, existing)
+		if err != nil {
+			return err
+		}
+		count, err = engine.attach(ctx, relation, pivot, localID, attach, extra, tx)
+		return err
+	})
+	return count, err
+}
+
+// Detach 删除多对多关系的中间表中id与ids的关联，ids为nil时删除id的所有关联，返回删除的行数
+func (engine *Engine) Detach(name string, id interface{}, relationName string, ids []interface{}, tx ...*sqlx.Tx) (int64, error) {
+	return engine.DetachContext(context.Background(), name, id, relationName, ids, tx...)
+}
+
+// DetachContext 同Detach，ctx用于取消及超时
+func (engine *Engine) DetachContext(ctx context.Context, name string, id interface{}, relationName string, ids []interface{}, tx ...*sqlx.Tx) (int64, error) {
+	relation, pivot, err := engine.pivotOf(name, relationName)
+	if err != nil {
+		return 0, err
+	}
+	if ids != nil {
+		if ids, err = pivotDiff(pivot, relation, ids, nil); err != nil || len(ids) == 0 {
+			return 0, err
+		}
+	}
+	localID, err := schema.Convert(pivot.GetFieldByColumn(relation.Pivot.LocalKey).Type, id)
+	if err != nil {
+		return 0, err
+	}
+	return engine.detach(ctx, relation, localID, ids, tx...)
+}
+
+// Sync 将多对多关系中id的关联同步为ids，在一个事务中删除不在ids中的关联并添加新的关联
+// extra为中间表中其他列的值，只应用于新增的关联，已有关联的其他列不变
+func (engine *Engine) Sync(name string, id interface{}, relationName string, ids []interface{}, extra map[string]interface{}, tx ...*sqlx.Tx) (*SyncResult, error) {
+	return engine.SyncContext(context.Background(), name, id, relationName, ids, extra, tx...)
+}
+
+// SyncContext 同Sync，ctx用于取消及超时
+func (engine *Engine) SyncContext(ctx context.Context, name string, id interface{}, relationName string, ids []interface{}, extra map[string]interface{}, tx ...*sqlx.Tx) (*SyncResult, error) {
+	relation, pivot, err := engine.pivotOf(name, relationName)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{}
+	err = engine.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		localID, existing, err := engine.pivotIDs(ctx, relation, pivot, id, tx)
+		if err != nil {
+			return err
+		}
+		wanted, err := pivotDiff(pivot, relation, ids, nil)
+		if err != nil {
+			return err
+		}
+		if result.Attached, err = pivotDiff(pivot, relation, wanted, existing); err != nil {
+			return err
+		}
+		if result.Detached, err = pivotDiff(pivot, relation, existing, wanted); err != nil {
+			return err
+		}
+		if len(result.Detached) > 0 {
+			if _, err = engine.detach(ctx, relation, localID, result.Detached, tx); err != nil {
+				return err
+			}
+		}
+		_, err = engine.attach(ctx, relation, pivot, localID, result.Attached, extra, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pivotIDs 按中间表的列类型转换id，并查询id已关联的关联模型id
+func (engine *Engine) pivotIDs(ctx context.Context, relation *schema.Relation, pivot *schema.Schema, id interface{}, tx *sqlx.Tx) (interface{}, []interface{}, error) {
+	localID, err := schema.Convert(pivot.GetFieldByColumn(relation.Pivot.LocalKey).Type, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	sql, args, err := engine.dialect.BuildSelect(pivot.TableName, []string{relation.Pivot.ForeignKey}, map[string]interface{}{relation.Pivot.LocalKey: localID})
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := engine.queryRows(ctx, pivot, sql, args, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]interface{}, len(rows))
+	for i, row := range rows {
+		ids[i] = row[relation.Pivot.ForeignKey]
+	}
+	return localID, ids, nil
+}
+
+// pivotDiff 按关联模型主键的类型转换ids，返回不在existing中的id，ids中重复的id只返回一次
+func pivotDiff(pivot *schema.Schema, relation *schema.Relation, ids, existing []interface{}) ([]interface{}, error) {
+	typ := pivot.GetFieldByColumn(relation.Pivot.ForeignKey).Type
+	// 数据库返回的值与传入的值类型可能不同，按字符串比较
+	seen := make(map[string]bool, len(existing)+len(ids))
+	for _, v := range existing {
+		seen[fmt.Sprint(v)] = true
+	}
+	var diff []interface{}
+	for _, v := range ids {
+		converted, err := schema.Convert(typ, v)
+		if err != nil {
+			return nil, err
+		}
+		if key := fmt.Sprint(converted); !seen[key] {
+			seen[key] = true
+			diff = append(diff, converted)
+		}
+	}
+	return diff, nil
+}
+
+// attach 在中间表中插入关联
+func (engine *Engine) attach(ctx context.Context, relation *schema.Relation, pivot *schema.Schema, localID interface{}, ids []interface{}, extra map[string]interface{}, tx *sqlx.Tx) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	data := make([]map[string]interface{}, len(ids))
+	for i, v := range ids {
+		row := make(map[string]interface{}, len(extra)+2)
+		for k, value := range extra {
+			row[k] = value
+		}
+		row[relation.Pivot.LocalKey] = localID
+		row[relation.Pivot.ForeignKey] = v
+		data[i] = row
+	}
+	rows, err := insertRows(pivot, data)
+	if err != nil {
+		return 0, err
+	}
+	sql, args, err := engine.dialect.BuildInsert(pivot.TableName, rows)
+	if err != nil {
+		return 0, err
+	}
+	return engine.exec(ctx, sql, args, tx)
+}
+
+// detach 删除中间表中的关联，ids为nil时删除localID的所有关联
+func (engine *Engine) detach(ctx context.Context, relation *schema.Relation, localID interface{}, ids []interface{}, tx ...*sqlx.Tx) (int64, error) {
+	where := map[string]interface{}{relation.Pivot.LocalKey: localID}
+	if ids != nil {
+		where[relation.Pivot.ForeignKey+" in"] = ids
+	}
+	sql, args, err := engine.dialect.BuildDelete(relation.Pivot.Table, where)
+	if err != nil {
+		return 0, err
+	}
+	return engine.exec(ctx, sql, args, tx...)
+}
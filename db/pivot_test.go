@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"testing"
+)
+
+func TestEngine_PivotSQL(t *testing.T) {
+	Convey("多对多关系的中间表", t, func() {
+		engine := newTestEngine(roleDef, memberDef, postDef, tagDef)
+
+		Convey("中间表的建表语句", func() {
+			sqls, err := engine.PivotTableSQL("member")
+			So(err, ShouldBeNil)
+			So(sqls, ShouldResemble, []string{
+				"CREATE TABLE IF NOT EXISTS `member_tag` (`member_id` bigint NOT NULL,`tag_id` bigint NOT NULL, PRIMARY KEY(`member_id`,`tag_id`))",
+				"CREATE INDEX `IDX_MEMBER_TAG_TAG_ID` ON `member_tag` (`tag_id`)",
+			})
+			sqls, err = engine.PivotTableSQL("tag")
+			So(err, ShouldBeNil)
+			So(sqls, ShouldBeEmpty)
+		})
+
+		Convey("关联模型未注册", func() {
+			engine := newTestEngine(memberDef)
+			_, err := engine.PivotTableSQL("member")
+			So(err, ShouldEqual, ErrSchemaNotRegistered)
+		})
+
+		Convey("只能管理多对多关系", func() {
+			_, err := engine.Attach("member", 1, "role", []interface{}{1}, nil)
+			So(errors.Is(err, schema.ErrInvalidRelation), ShouldBeTrue)
+			_, err = engine.Sync("member", 1, "unknown", nil, nil)
+			So(errors.Is(err, schema.ErrInvalidRelation), ShouldBeTrue)
+		})
+
+		Convey("离线引擎", func() {
+			_, err := engine.Attach("member", 1, "tags", []interface{}{1, 2}, nil)
+			So(err, ShouldEqual, ErrEngineOffline)
+			_, err = engine.Detach("member", 1, "tags", nil)
+			So(err, ShouldEqual, ErrEngineOffline)
+			_, err = engine.Sync("member", 1, "tags", []interface{}{1}, nil)
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+	})
+}
+
+// pivotRows 中间表查询返回已关联的tag_id
+func pivotRows(ids ...int64) func(sql string, args []driver.NamedValue) *fakeRows {
+	return func(sql string, args []driver.NamedValue) *fakeRows {
+		rows := &fakeRows{columns: []string{"tag_id"}}
+		for _, id := range ids {
+			rows.values = append(rows.values, []driver.Value{id})
+		}
+		return rows
+	}
+}
+
+func TestEngine_PivotStatements(t *testing.T) {
+	Convey("多对多关联的维护", t, func() {
+		engine, fake := newFakeEngine(roleDef, memberDef, postDef, tagDef)
+
+		Convey("按类型转换后去掉已有及重复的id", func() {
+			relation, pivot, err := engine.pivotOf("member", "tags")
+			So(err, ShouldBeNil)
+			diff, err := pivotDiff(pivot, relation, []interface{}{"1", 2, int64(3), "2"}, []interface{}{int64(1)})
+			So(err, ShouldBeNil)
+			So(diff, ShouldResemble, []interface{}{int64(2), int64(3)})
+			diff, err = pivotDiff(pivot, relation, []interface{}{1}, []interface{}{"1"})
+			So(err, ShouldBeNil)
+			So(diff, ShouldBeEmpty)
+			_, err = pivotDiff(pivot, relation, []interface{}{"x"}, nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Attach只插入不存在的关联", func() {
+			fake.query = pivotRows(1)
+			n, err := engine.Attach("member", "9", "tags", []interface{}{1, 2, 3}, nil)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SELECT `tag_id` FROM `member_tag` WHERE (`member_id` = 9)",
+				"INSERT INTO `member_tag` (`member_id`, `tag_id`) VALUES (9, 2), (9, 3)",
+				"COMMIT",
+			})
+		})
+
+		Convey("全部已关联时不插入", func() {
+			fake.query = pivotRows(1, 2)
+			n, err := engine.Attach("member", 9, "tags", []interface{}{2, 1}, nil)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(writes(fake.statements()), ShouldResemble, []string{"BEGIN", "COMMIT"})
+		})
+
+		Convey("Detach", func() {
+			_, err := engine.Detach("member", 9, "tags", []interface{}{"2", 3, 3})
+			So(err, ShouldBeNil)
+			_, err = engine.Detach("member", 9, "tags", nil)
+			So(err, ShouldBeNil)
+			n, err := engine.Detach("member", 9, "tags", []interface{}{})
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+			So(fake.statements(), ShouldResemble, []string{
+				"DELETE `member_tag` FROM `member_tag` WHERE ((`member_id` = 9) AND (`tag_id` IN (2, 3)))",
+				"DELETE `member_tag` FROM `member_tag` WHERE (`member_id` = 9)",
+			})
+		})
+
+		Convey("Sync先删除多余的关联再插入新的关联", func() {
+			fake.query = pivotRows(1, 2)
+			result, err := engine.Sync("member", 9, "tags", []interface{}{2, 3}, nil)
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, &SyncResult{Attached: []interface{}{int64(3)}, Detached: []interface{}{int64(1)}})
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"BEGIN",
+				"DELETE `member_tag` FROM `member_tag` WHERE ((`member_id` = 9) AND (`tag_id` IN (1)))",
+				"INSERT INTO `member_tag` (`member_id`, `tag_id`) VALUES (9, 3)",
+				"COMMIT",
+			})
+		})
+
+		Convey("Sync为空时删除所有关联", func() {
+			fake.query = pivotRows(1)
+			result, err := engine.Sync("member", 9, "tags", nil, nil)
+			So(err, ShouldBeNil)
+			So(result.Attached, ShouldBeEmpty)
+			So(result.Detached, ShouldResemble, []interface{}{int64(1)})
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"BEGIN",
+				"DELETE `member_tag` FROM `member_tag` WHERE ((`member_id` = 9) AND (`tag_id` IN (1)))",
+				"COMMIT",
+			})
+		})
+	})
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+var (
+	ErrRelationNotFound error = errors.New("relation not found")
+)
+
+// resolveJoins 根据条件、排序、分组及查询字段中 关系名.字段 形式的引用生成关联表的连接
+// 返回的map为 关系别名 => 关联模型，用于解码关联字段
+func (engine *Engine) resolveJoins(s *schema.Schema, where map[string]interface{}, selectFields []string) ([]dialect.Join, map[string]*schema.Schema, error) {
+	var joins []dialect.Join
+	related := make(map[string]*schema.Schema)
+	for _, alias := range relationRefs(where, selectFields) {
+		relation := s.GetRelation(alias)
+		if relation == nil {
+			// json字段的路径，如：profile.address.city
+			if field := s.GetFieldByColumn(alias); field != nil && field.Type == "json" {
+				continue
+			}
+			return nil, nil, fmt.Errorf("%w: %s", ErrRelationNotFound, alias)
+		}
+		target := engine.GetSchema(relation.Model)
+		if target == nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, relation.Model)
+		}
+		relationJoins, err := joinsOf(s, target, relation)
+		if err != nil {
+			return nil, nil, err
+		}
+		joins = append(joins, relationJoins...)
+		related[relation.Alias()] = target
+	}
+	return joins, related, nil
+}
+
+// checkJSONPaths 更新、删除及统计不连接关联表，条件中的 名称.路径 只能是json字段的路径，否则返回ErrRelationNotFound
+func checkJSONPaths(s *schema.Schema, where map[string]interface{}) error {
+	for _, alias := range relationRefs(where, nil) {
+		if field := s.GetFieldByColumn(alias); field == nil || field.Type != "json" {
+			return fmt.Errorf("%w: %s", ErrRelationNotFound, alias)
+		}
+	}
+	return nil
+}
+
+// joinsOf 生成关系对应的表连接，多对多关系需要先连接中间表
+func joinsOf(s, target *schema.Schema, relation *schema.Relation) ([]dialect.Join, error) {
+	alias := relation.Alias()
+	switch relation.Type {
+	case schema.RELATION_BELONGS_TO:
+		pk := target.PrimaryKey()
+		if pk == nil {
+			return nil, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidRelation, target.Name)
+		}
+		return []dialect.Join{{
+			Table:  target.TableName,
+			Alias:  alias,
+			Column: pk.Column,
+			Ref:    s.TableName + "." + relation.Field,
+		}}, nil
+	case schema.RELATION_HAS_ONE, schema.RELATION_HAS_MANY:
+		pk := s.PrimaryKey()
+		if pk == nil {
+			return nil, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidRelation, s.Name)
+		}
+		if target.GetFieldByColumn(relation.Field) == nil {
+			return nil, fmt.Errorf("%w: relation %s field %s not found in model %s", schema.ErrInvalidRelation, relation.Name, relation.Field, target.Name)
+		}
+		return []dialect.Join{{
+			Table:  target.TableName,
+			Alias:  alias,
+			Column: relation.Field,
+			Ref:    s.TableName + "." + pk.Column,
+			ToMany: relation.IsToMany(),
+		}}, nil
+	case schema.RELATION_MANY_TO_MANY:
+		pk, targetPk := s.PrimaryKey(), target.PrimaryKey()
+		if pk == nil || targetPk == nil {
+			return nil, fmt.Errorf("%w: relation %s requires primary keys on both models", schema.ErrInvalidRelation, relation.Name)
+		}
+		pivotAlias := alias + "_pivot"
+		return []dialect.Join{
+			{
+				Table:  relation.Pivot.Table,
+				Alias:  pivotAlias,
+				Column: relation.Pivot.LocalKey,
+				Ref:    s.TableName + "." + pk.Column,
+				ToMany: true,
+			},
+			{
+				Table:  target.TableName,
+				Alias:  alias,
+				Column: targetPk.Column,
+				Ref:    pivotAlias + "." + relation.Pivot.ForeignKey,
+				ToMany: true,
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: relation %s has invalid type %q", schema.ErrInvalidRelation, relation.Name, relation.Type)
+}
+
+// relationRefs 收集条件、排序、分组及查询字段中引用的关系名，如：role.name 中的 role
+func relationRefs(where map[string]interface{}, selectFields []string) []string {
+	refs := make(map[string]bool)
+	addRef := func(field string) {
+		field = strings.SplitN(strings.TrimSpace(field), " ", 2)[0]
+		if alias, _, ok := strings.Cut(util.ToSnake(field), "."); ok {
+			refs[alias] = true
+		}
+	}
+	for _, f := range selectFields {
+		addRef(f)
+	}
+
+	var walk func(m map[string]interface{})
+	walk = func(m map[string]interface{}) {
+		for k, v := range m {
+			switch k {
+			case dialect.OP_ORDER_BY, dialect.OP_GROUP_BY:
+				switch s := v.(type) {
+				case string:
+					addRef(s)
+				case []string:
+					for _, e := range s {
+						addRef(e)
+					}
+				case []interface{}:
+					for _, e := range s {
+						if str, ok := e.(string); ok {
+							addRef(str)
+						}
+					}
+				}
+				continue
+			}
+			if !strings.HasPrefix(k, "$") {
+				addRef(k)
+			}
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(sub)
+			}
+		}
+	}
+	walk(where)
+
+	aliases := make([]string, 0, len(refs))
+	for alias := range refs {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// decodeRow 按照模型字段类型解码一行数据，关系名.字段 形式的列按照关联模型的字段类型解码，json路径的值按json解码
+func decodeRow(s *schema.Schema, related map[string]*schema.Schema, row map[string]interface{}) error {
+	for column, v := range row {
+		alias, col, ok := strings.Cut(column, ".")
+		if !ok {
+			continue
+		}
+		if related[alias] == nil {
+			if field := s.GetFieldByColumn(alias); field != nil && field.Type == "json" {
+				row[column] = decodeJSONValue(v)
+			}
+			continue
+		}
+		field := related[alias].GetFieldByColumn(col)
+		if field == nil {
+			continue
+		}
+		value, err := schema.Convert(field.ValueType(), v)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", column, err)
+		}
+		row[column] = value
+	}
+	return s.DecodeRow(row)
+}
+
+// decodeJSONValue 解码json路径取出的值，sqlite取出的字符串不是json格式，解码失败时返回字符串
+func decodeJSONValue(v interface{}) interface{} {
+	var s string
+	switch t := v.(type) {
+	case []byte:
+		s = string(t)
+	case string:
+		s = t
+	default:
+		return v
+	}
+	var r interface{}
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		return s
+	}
+	return r
+}
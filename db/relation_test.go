@@ -0,0 +1,159 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	_ "github.com/yaochi-tech/lingquan-core-go/db/dialect/mysql"
+	"testing"
+)
+
+const roleDef = `{
+  "code": "role",
+  "name": "角色",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "名称", "name": "name", "type": "string"}
+  ]
+}`
+
+const memberDef = `{
+  "code": "member",
+  "name": "成员",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "名称", "name": "name", "type": "string"},
+    {"label": "角色", "name": "roleId", "type": "int64"},
+    {"label": "资料", "name": "profile", "type": "json"}
+  ],
+  "relations": [
+    {"name": "role", "type": "belongsTo", "model": "role", "field": "role_id"},
+    {"name": "posts", "type": "hasMany", "model": "post", "field": "member_id"},
+    {"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id"}}
+  ]
+}`
+
+const postDef = `{
+  "code": "post",
+  "name": "文章",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "标题", "name": "title", "type": "string"},
+    {"label": "作者", "name": "memberId", "type": "int64"}
+  ]
+}`
+
+const tagDef = `{
+  "code": "tag",
+  "name": "标签",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "名称", "name": "name", "type": "string"}
+  ]
+}`
+
+// newTestEngine 创建不连接数据库的引擎，用于测试sql生成
+func newTestEngine(definitions ...string) *Engine {
+	engine, err := NewOfflineEngine("mysql")
+	if err != nil {
+		panic(err)
+	}
+	for _, def := range definitions {
+		if _, err := engine.Register(def); err != nil {
+			panic(err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_resolveJoins(t *testing.T) {
+	Convey("关联查询测试", t, func() {
+		engine := newTestEngine(roleDef, memberDef, postDef, tagDef)
+		s := engine.GetSchema("member")
+
+		buildSelect := func(where map[string]interface{}, fields []string) string {
+			joins, _, err := engine.resolveJoins(s, where, fields)
+			So(err, ShouldBeNil)
+			if len(joins) > 0 {
+				where[dialect.OP_JOIN] = joins
+			}
+			sql, _, err := engine.dialect.BuildSelect(s.TableName, fields, where)
+			So(err, ShouldBeNil)
+			return sql
+		}
+
+		Convey("belongsTo", func() {
+			sql := buildSelect(map[string]interface{}{"role.name": "admin", "$order_by": "name desc"}, []string{"id", "role.name"})
+			So(sql, ShouldEqual, "SELECT `member`.`id`, `role`.`name` AS `role.name` FROM `member` "+
+				"LEFT JOIN `role` AS `role` ON (`role`.`id` = `member`.`role_id`) "+
+				"WHERE (`role`.`name` = 'admin') ORDER BY `member`.`name` DESC")
+		})
+
+		Convey("hasMany", func() {
+			sql := buildSelect(map[string]interface{}{"posts.title like": "%go%"}, nil)
+			So(sql, ShouldEqual, "SELECT DISTINCT `member`.* FROM `member` "+
+				"LEFT JOIN `post` AS `posts` ON (`posts`.`member_id` = `member`.`id`) "+
+				"WHERE (`posts`.`title` LIKE BINARY '%go%')")
+		})
+
+		Convey("manyToMany", func() {
+			sql := buildSelect(map[string]interface{}{"$or": map[string]interface{}{"tags.name": "go", "id": 1}}, []string{"id"})
+			So(sql, ShouldEqual, "SELECT DISTINCT `member`.`id` FROM `member` "+
+				"LEFT JOIN `member_tag` AS `tags_pivot` ON (`tags_pivot`.`member_id` = `member`.`id`) "+
+				"LEFT JOIN `tag` AS `tags` ON (`tags`.`id` = `tags_pivot`.`tag_id`) "+
+				"WHERE ((`member`.`id` = 1) OR (`tags`.`name` = 'go'))")
+		})
+
+		Convey("一对多关联查询去重时只能按查询字段排序", func() {
+			sql := buildSelect(map[string]interface{}{"posts.title like": "%go%", "$order_by": "name desc"}, nil)
+			So(sql, ShouldEqual, "SELECT DISTINCT `member`.* FROM `member` "+
+				"LEFT JOIN `post` AS `posts` ON (`posts`.`member_id` = `member`.`id`) "+
+				"WHERE (`posts`.`title` LIKE BINARY '%go%') ORDER BY `member`.`name` DESC")
+
+			for _, fields := range [][]string{nil, {"id"}} {
+				where := map[string]interface{}{"posts.title like": "%go%", "$order_by": "posts.title"}
+				joins, _, err := engine.resolveJoins(s, where, fields)
+				So(err, ShouldBeNil)
+				where[dialect.OP_JOIN] = joins
+				_, _, err = engine.dialect.BuildSelect(s.TableName, fields, where)
+				So(err, ShouldWrap, dialect.ErrInvalidCondition)
+			}
+
+			sql = buildSelect(map[string]interface{}{"$order_by": "posts.title"}, []string{"id", "posts.title"})
+			So(sql, ShouldEqual, "SELECT DISTINCT `member`.`id`, `posts`.`title` AS `posts.title` FROM `member` "+
+				"LEFT JOIN `post` AS `posts` ON (`posts`.`member_id` = `member`.`id`) "+
+				"ORDER BY `posts`.`title` ASC")
+		})
+
+		Convey("json字段路径不需要连接", func() {
+			sql := buildSelect(map[string]interface{}{"profile.homeCity": "北京"}, []string{"id"})
+			So(sql, ShouldEqual, "SELECT `id` FROM `member` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`profile`, '$.homeCity')) = '北京')")
+		})
+
+		Convey("未定义的关系", func() {
+			_, _, err := engine.resolveJoins(s, map[string]interface{}{"group.name": "a"}, nil)
+			So(err, ShouldWrap, ErrRelationNotFound)
+		})
+
+		Convey("更新、删除只能使用json字段的路径", func() {
+			sql, _, err := engine.DeleteSQL("member", map[string]interface{}{"profile.homeCity": "北京"})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "DELETE `member` FROM `member` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`profile`, '$.homeCity')) = '北京')")
+
+			// 更新、删除不连接关联表，关系及非json字段不能作为路径
+			for _, cond := range []map[string]interface{}{
+				{"role.name": "admin"},
+				{"$or": map[string]interface{}{"id": 1, "name.first": "a"}},
+				{"group.name": "a"},
+			} {
+				_, _, err = engine.UpdateSQL("member", map[string]interface{}{"name": "a"}, cond)
+				So(err, ShouldWrap, ErrRelationNotFound)
+				_, _, err = engine.DeleteSQL("member", cond)
+				So(err, ShouldWrap, ErrRelationNotFound)
+				_, err = engine.DeleteDryRun("member", cond)
+				So(err, ShouldWrap, ErrRelationNotFound)
+				_, err = engine.UpdateDryRun("member", map[string]interface{}{"name": "a"}, cond)
+				So(err, ShouldWrap, ErrRelationNotFound)
+			}
+		})
+	})
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// InsertReturning 插入数据，返回插入后的完整数据（包含数据库默认值及生成的主键），顺序与data相同
+// postgres、sqlite使用RETURNING，其他数据库插入后按主键重新查询，此时每行都必须有主键（传入或由生成器生成）
+func (engine *Engine) InsertReturning(name string, data []map[string]interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	return engine.InsertReturningContext(context.Background(), name, data, tx...)
+}
+
+// InsertReturningContext 同InsertReturning，ctx用于取消及超时
+func (engine *Engine) InsertReturningContext(ctx context.Context, name string, data []map[string]interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	sql, args, ids, err := engine.buildInsert(s, data)
+	if err != nil {
+		return nil, err
+	}
+	if returning, ok := engine.dialect.Returning(sql); ok {
+		return engine.queryRows(ctx, s, returning, args, tx...)
+	}
+
+	pk := s.PrimaryKey()
+	if pk == nil {
+		return nil, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidField, s.Name)
+	}
+	for i, id := range ids {
+		if id == nil {
+			return nil, fmt.Errorf("%w: row %d has no primary key", schema.ErrInvalidField, i)
+		}
+	}
+	if _, err = engine.exec(ctx, sql, args, tx...); err != nil {
+		return nil, err
+	}
+	return engine.findByIDs(ctx, s, pk, ids, tx...)
+}
+
+// UpdateReturning 更新数据，返回更新后的完整数据，条件同Update
+// postgres、sqlite使用RETURNING，其他数据库在事务中先锁定并查询符合条件的主键，按主键更新后重新查询，没有传入事务时使用单独的事务
+// 按主键更新时影响的行数必须与锁定的行数一致，mysql连接需设置clientFoundRows=true，否则值未变化的行不计入影响的行数
+// 条件中的$max_affected同Update，超过时回滚并返回ErrTooManyAffected，传入事务时由调用者回滚
+func (engine *Engine) UpdateReturning(name string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	return engine.UpdateReturningContext(context.Background(), name, data, namedCondition, tx...)
+}
+
+// UpdateReturningContext 同UpdateReturning，ctx用于取消及超时
+func (engine *Engine) UpdateReturningContext(ctx context.Context, name string, data, namedCondition map[string]interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	data, namedCondition, checked, err := updateRow(s, data, namedCondition)
+	if err != nil {
+		return nil, err
+	}
+	sql, args, err := engine.buildUpdate(s, data, namedCondition)
+	if err != nil {
+		return nil, err
+	}
+	limit, bounded, err := maxAffected(namedCondition)
+	if err != nil {
+		return nil, err
+	}
+	returning, ok := engine.dialect.Returning(sql)
+	if ok && !bounded {
+		rows, err := engine.queryRows(ctx, s, returning, args, tx...)
+		if err == nil && len(rows) == 0 && checked {
+			return nil, ErrStaleRecord
+		}
+		return rows, err
+	}
+
+	pk := s.PrimaryKey()
+	if !ok && pk == nil {
+		return nil, fmt.Errorf("%w: model %s has no primary key", schema.ErrInvalidField, s.Name)
+	}
+	var ownTx *sqlx.Tx
+	if len(tx) == 0 || tx[0] == nil {
+		if engine.DB == nil {
+			return nil, ErrEngineOffline
+		}
+		if ownTx, err = engine.DB.BeginTxx(ctx, nil); err != nil {
+			return nil, err
+		}
+		tx = []*sqlx.Tx{ownTx}
+	}
+
+	var rows []map[string]interface{}
+	if ok {
+		rows, err = engine.queryRows(ctx, s, returning, args, tx[0])
+	} else {
+		rows, err = engine.updateByIDs(ctx, s, pk, data, namedCondition, tx[0])
+	}
+	if err == nil && bounded && int64(len(rows)) > limit {
+		err = fmt.Errorf("%w: %d rows affected, limit is %d", ErrTooManyAffected, len(rows), limit)
+	}
+	if err == nil && len(rows) == 0 && checked {
+		err = ErrStaleRecord
+	}
+	if ownTx != nil {
+		if err != nil {
+			_ = ownTx.Rollback()
+			return nil, err
+		}
+		if err = ownTx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return rows, err
+}
+
+// updateByIDs 锁定并查询将要更新的行的主键，按主键及条件更新后重新查询更新的行，data及条件中已处理版本号
+// 查询主键时同样使用data中$non_negative对应的条件，更新的行数与主键数不一致时返回ErrAffectedMismatch
+func (engine *Engine) updateByIDs(ctx context.Context, s *schema.Schema, pk *schema.Field, data, namedCondition map[string]interface{}, tx *sqlx.Tx) ([]map[string]interface{}, error) {
+	where, err := engine.resolveSubQueries(namedCondition)
+	if err != nil {
+		return nil, err
+	}
+	sql, args, err := engine.dialect.BuildUpdateTargets(s.TableName, pk.Column, data, where)
+	if err != nil {
+		return nil, err
+	}
+	found, err := engine.queryRows(ctx, s, sql, args, tx)
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	ids := make([]interface{}, len(found))
+	for i, row := range found {
+		ids[i] = row[pk.Column]
+	}
+
+	sql, args, err = engine.buildUpdate(s, data, map[string]interface{}{
+		dialect.OP_AND:    namedCondition,
+		pk.Column + " in": ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	count, err := engine.exec(ctx, sql, args, tx)
+	if err != nil {
+		return nil, err
+	}
+	if count != int64(len(ids)) {
+		return nil, fmt.Errorf("%w: %d rows locked, %d rows updated", ErrAffectedMismatch, len(ids), count)
+	}
+	return engine.findByIDs(ctx, s, pk, ids, tx)
+}
+
+// findByIDs 按主键查询数据，结果的顺序与ids相同，不存在的主键被忽略
+func (engine *Engine) findByIDs(ctx context.Context, s *schema.Schema, pk *schema.Field, ids []interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	found, err := engine.FindContext(ctx, s.Name, map[string]interface{}{pk.Column + " in": ids}, nil, tx...)
+	if err != nil {
+		return nil, err
+	}
+	// 查询结果的主键已按字段类型解码，与传入的主键类型可能不同，按字符串匹配
+	byID := make(map[string]map[string]interface{}, len(found))
+	for _, row := range found {
+		byID[fmt.Sprint(row[pk.Column])] = row
+	}
+	rows := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if row, ok := byID[fmt.Sprint(id)]; ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// queryRows 执行返回数据的语句，按照模型字段类型解码
+func (engine *Engine) queryRows(ctx context.Context, s *schema.Schema, sql string, args []interface{}, tx ...*sqlx.Tx) ([]map[string]interface{}, error) {
+	ex, err := engine.executor(tx...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ex.QueryxContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(s, nil, rows)
+}
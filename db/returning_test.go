@@ -0,0 +1,103 @@
+package db
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+)
+
+const productDef = `{
+  "code": "product",
+  "name": "商品",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "库存", "name": "stock", "type": "int"}
+  ]
+}`
+
+func TestEngine_UpdateReturningByIDs(t *testing.T) {
+	Convey("不支持RETURNING时按主键更新", t, func() {
+		engine, fake := newFakeEngine(productDef)
+		fake.query = func(sql string, args []driver.NamedValue) *fakeRows {
+			if strings.Contains(sql, "FOR UPDATE") {
+				return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(1)}, {int64(2)}}}
+			}
+			return &fakeRows{columns: []string{"id", "stock"}, values: [][]driver.Value{{int64(2), int64(0)}, {int64(1), int64(3)}}}
+		}
+		fake.exec = func(string) (int64, error) { return 2, nil }
+		data := map[string]interface{}{"stock -": 2, "$non_negative": "stock"}
+
+		Convey("锁定的主键包含$non_negative的条件", func() {
+			rows, err := engine.UpdateReturning("product", data, map[string]interface{}{"id in": []int{1, 2, 3}})
+			So(err, ShouldBeNil)
+			So(rows, ShouldResemble, []map[string]interface{}{{"id": int64(1), "stock": int64(3)}, {"id": int64(2), "stock": int64(0)}})
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SELECT `id` FROM `product` WHERE ((`id` IN (1, 2, 3)) AND `stock` - 2 >= 0) FOR UPDATE ",
+				"UPDATE `product` SET `stock`=`stock` - 2 WHERE ((`id` IN (1, 2, 3)) AND (`id` IN (1, 2)) AND `stock` - 2 >= 0)",
+				"SELECT * FROM `product` WHERE (`id` IN (1, 2))",
+				"COMMIT",
+			})
+		})
+
+		Convey("更新的行数与锁定的行数不一致时回滚", func() {
+			fake.exec = func(string) (int64, error) { return 1, nil }
+			_, err := engine.UpdateReturning("product", data, map[string]interface{}{"id in": []int{1, 2, 3}})
+			So(err, ShouldWrap, ErrAffectedMismatch)
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"BEGIN",
+				"UPDATE `product` SET `stock`=`stock` - 2 WHERE ((`id` IN (1, 2, 3)) AND (`id` IN (1, 2)) AND `stock` - 2 >= 0)",
+				"ROLLBACK",
+			})
+		})
+
+		Convey("超过$max_affected时回滚", func() {
+			_, err := engine.UpdateReturning("product", data, map[string]interface{}{"id in": []int{1, 2, 3}, "$max_affected": 1})
+			So(err, ShouldWrap, ErrTooManyAffected)
+			So(writes(fake.statements()), ShouldResemble, []string{
+				"BEGIN",
+				"UPDATE `product` SET `stock`=`stock` - 2 WHERE ((`id` IN (1, 2, 3)) AND (`id` IN (1, 2)) AND `stock` - 2 >= 0)",
+				"ROLLBACK",
+			})
+		})
+	})
+}
+
+func TestEngine_UpdateReturningMaxAffected(t *testing.T) {
+	Convey("RETURNING更新时限制影响的行数", t, func() {
+		engine, fake := newFakeEngine(productDef)
+		// postgres使用RETURNING
+		engine.driverName = dialect.POSTGRES
+		engine.dialect, _ = dialect.GetDialect(dialect.POSTGRES)
+		fake.query = func(string, []driver.NamedValue) *fakeRows {
+			return &fakeRows{columns: []string{"id", "stock"}, values: [][]driver.Value{{int64(1), int64(3)}, {int64(2), int64(0)}}}
+		}
+		data := map[string]interface{}{"stock -": 2}
+
+		Convey("超过时回滚", func() {
+			_, err := engine.UpdateReturning("product", data, map[string]interface{}{"id in": []int{1, 2}, "$max_affected": 1})
+			So(err, ShouldWrap, ErrTooManyAffected)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				`UPDATE "product" SET "stock"="stock" - 2 WHERE ("id" IN (1, 2)) RETURNING *`,
+				"ROLLBACK",
+			})
+		})
+
+		Convey("未超过时提交", func() {
+			rows, err := engine.UpdateReturning("product", data, map[string]interface{}{"id in": []int{1, 2}, "$max_affected": 2})
+			So(err, ShouldBeNil)
+			So(rows, ShouldHaveLength, 2)
+			So(writes(fake.statements()), ShouldResemble, []string{"BEGIN", `UPDATE "product" SET "stock"="stock" - 2 WHERE ("id" IN (1, 2)) RETURNING *`, "COMMIT"})
+		})
+
+		Convey("没有$max_affected时不使用事务", func() {
+			_, err := engine.UpdateReturning("product", data, map[string]interface{}{"id in": []int{1, 2}})
+			So(err, ShouldBeNil)
+			So(fake.statements(), ShouldResemble, []string{`UPDATE "product" SET "stock"="stock" - 2 WHERE ("id" IN (1, 2)) RETURNING *`})
+		})
+	})
+}
@@ -0,0 +1,283 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidValue error = errors.New("invalid value")
+)
+
+// 支持的时间格式，按顺序尝试解析
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// IsValidType 判断是否为支持的字段类型
+func IsValidType(typ string) bool {
+	switch strings.ToLower(typ) {
+	case "id", "string", "text", "json", "date", "datetime",
+		"int", "int64", "uint", "uint64", "bool",
+		"float", "double", "float32", "float64":
+		return true
+	}
+	return false
+}
+
+// Convert 将值转换为字段类型对应的go类型，数据库返回的[]byte也会按照字段类型解析
+// 整数类型统一转换为int64或uint64，浮点类型统一转换为float64，时间类型转换为time.Time，json类型转换为解析后的对象
+func Convert(typ string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	switch strings.ToLower(typ) {
+	case "string", "text":
+		switch s := v.(type) {
+		case string:
+			return s, nil
+		case time.Time:
+			return s.Format(time.RFC3339), nil
+		case fmt.Stringer:
+			return s.String(), nil
+		}
+		return fmt.Sprint(v), nil
+	case "id", "int", "int64":
+		return toInt64(typ, v)
+	case "uint", "uint64":
+		return toUint64(typ, v)
+	case "float", "double", "float32", "float64":
+		if n, ok := number(v); ok {
+			return n, nil
+		}
+		if s, ok := v.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: cannot convert %q to %s", ErrInvalidValue, s, typ)
+			}
+			return f, nil
+		}
+	case "bool":
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			r, err := strconv.ParseBool(strings.TrimSpace(b))
+			if err != nil {
+				return nil, fmt.Errorf("%w: cannot convert %q to %s", ErrInvalidValue, b, typ)
+			}
+			return r, nil
+		}
+		if n, ok := number(v); ok {
+			return n != 0, nil
+		}
+	case "date", "datetime":
+		switch t := v.(type) {
+		case time.Time:
+			return t, nil
+		case string:
+			for _, layout := range timeLayouts {
+				if r, err := time.ParseInLocation(layout, strings.TrimSpace(t), time.Local); err == nil {
+					return r, nil
+				}
+			}
+			return nil, fmt.Errorf("%w: cannot convert %q to %s", ErrInvalidValue, t, typ)
+		}
+		// 数字为秒级时间戳，json解析的时间戳为float64，只接受没有小数部分的值
+		if n, ok := number(v); ok && n == math.Trunc(n) {
+			return time.Unix(int64(n), 0), nil
+		}
+	case "json":
+		if s, ok := v.(string); ok {
+			var r interface{}
+			if err := json.Unmarshal([]byte(s), &r); err != nil {
+				return nil, fmt.Errorf("%w: cannot convert %q to %s", ErrInvalidValue, s, typ)
+			}
+			return r, nil
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%w: cannot convert %T to %s", ErrInvalidValue, v, typ)
+}
+
+// EncodeValue 将写入的值转换为字段类型对应的数据库值，无法转换时返回ErrInvalidValue
+// 整数、浮点、布尔同Convert；时间字符串及时间戳解析后格式化为本地时间，time.Time原样返回；
+// json类型的非字符串值序列化为json字符串，字符串必须是合法的json
+func EncodeValue(typ string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	switch strings.ToLower(typ) {
+	case "json":
+		if s, ok := v.(string); ok {
+			if !json.Valid([]byte(s)) {
+				return nil, fmt.Errorf("%w: %q is not valid json", ErrInvalidValue, s)
+			}
+			return s, nil
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot marshal %T to json: %v", ErrInvalidValue, v, err)
+		}
+		return string(b), nil
+	case "date", "datetime":
+		if t, ok := v.(time.Time); ok {
+			return t, nil
+		}
+		r, err := Convert(typ, v)
+		if err != nil {
+			return nil, err
+		}
+		layout := "2006-01-02 15:04:05.999999"
+		if strings.ToLower(typ) == "date" {
+			layout = "2006-01-02"
+		}
+		return r.(time.Time).In(time.Local).Format(layout), nil
+	}
+	return Convert(typ, v)
+}
+
+func toInt64(typ string, v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+	case float32:
+		if float32(int64(n)) == n {
+			return int64(n), nil
+		}
+	case float64:
+		// json解析的数字都是float64，只接受没有小数部分的值
+		if float64(int64(n)) == n {
+			return int64(n), nil
+		}
+	case bool:
+		if n {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err == nil {
+			return i, nil
+		}
+		return 0, fmt.Errorf("%w: cannot convert %q to %s", ErrInvalidValue, n, typ)
+	}
+	return 0, fmt.Errorf("%w: cannot convert %v (%T) to %s", ErrInvalidValue, v, v, typ)
+}
+
+// number 将整数及浮点类型的值转为float64，其他类型返回false
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// toUint64 转换无符号整数，uint64及字符串直接按无符号解析，超过MaxInt64的值不会溢出
+func toUint64(typ string, v interface{}) (uint64, error) {
+	switch n := v.(type) {
+	case uint:
+		return uint64(n), nil
+	case uint8:
+		return uint64(n), nil
+	case uint16:
+		return uint64(n), nil
+	case uint32:
+		return uint64(n), nil
+	case uint64:
+		return n, nil
+	case string:
+		i, err := strconv.ParseUint(strings.TrimSpace(n), 10, 64)
+		if err == nil {
+			return i, nil
+		}
+		return 0, fmt.Errorf("%w: cannot convert %q to %s", ErrInvalidValue, n, typ)
+	}
+	i, err := toInt64(typ, v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("%w: %v is negative for %s", ErrInvalidValue, v, typ)
+	}
+	return uint64(i), nil
+}
+
+// DecodeRow 按照模型字段类型解码数据库返回的一行数据，非模型字段的[]byte值会转为string
+func (schema *Schema) DecodeRow(row map[string]interface{}) error {
+	for column, v := range row {
+		field := schema.GetFieldByColumn(column)
+		if field == nil {
+			if b, ok := v.([]byte); ok {
+				row[column] = string(b)
+			}
+			continue
+		}
+		value, err := Convert(field.ValueType(), v)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		row[column] = value
+	}
+	return nil
+}
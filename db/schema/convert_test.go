@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"json解析的整数", "int64", float64(3), int64(3), false},
+		{"带小数的整数", "int", 1.5, nil, true},
+		{"字符串转整数", "int", "12", int64(12), false},
+		{"字符串转布尔", "bool", "true", true, false},
+		{"超过MaxInt64的无符号整数", "uint64", uint64(math.MaxUint64), uint64(math.MaxUint64), false},
+		{"超过MaxInt64的无符号字符串", "uint64", "18446744073709551615", uint64(math.MaxUint64), false},
+		{"负数转无符号整数", "uint", -1, nil, true},
+		{"溢出的有符号整数", "int64", uint64(math.MaxUint64), nil, true},
+		{"json对象", "json", map[string]interface{}{"a": 1}, `{"a":1}`, false},
+		{"json字符串", "json", `["a"]`, `["a"]`, false},
+		{"非法json字符串", "json", "abc", nil, true},
+		{"日期时间", "datetime", "2023-01-02T03:04:05", "2023-01-02 03:04:05", false},
+		{"日期", "date", "2023-01-02", "2023-01-02", false},
+		{"非法时间", "datetime", "yesterday", nil, true},
+		{"空值", "int", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeValue(tt.typ, tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncodeValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("EncodeValue() error = %v, want ErrInvalidValue", err)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("EncodeValue() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+
+	now := time.Now()
+	if got, _ := EncodeValue("datetime", now); got != now {
+		t.Errorf("EncodeValue() = %v, want %v", got, now)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"int32转浮点", "float", int32(3), float64(3), false},
+		{"uint转浮点", "double", uint(3), float64(3), false},
+		{"int8转布尔", "bool", int8(1), true, false},
+		{"uint32转布尔", "bool", uint32(0), false, false},
+		{"int时间戳", "datetime", 86400, time.Unix(86400, 0), false},
+		{"json解析的时间戳", "date", float64(86400), time.Unix(86400, 0), false},
+		{"带小数的时间戳", "datetime", 1.5, nil, true},
+		{"布尔不能转为时间", "datetime", true, nil, true},
+		{"布尔不能转为浮点", "float", true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.typ, tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("Convert() error = %v, want ErrInvalidValue", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Convert() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_DecodeRow(t *testing.T) {
+	s := Parse(`{
+  "code": "user",
+  "name": "用户",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "用户名", "name": "userName", "type": "string", "length": 20},
+    {"label": "启用", "name": "enabled", "type": "bool"},
+    {"label": "扩展", "name": "extra", "type": "json"}
+  ]
+}`)
+
+	// mysql驱动对大部分列返回[]byte，解码后才是字段类型对应的go类型
+	row := map[string]interface{}{
+		"id":        []byte("7"),
+		"user_name": []byte("a"),
+		"enabled":   int64(1),
+		"extra":     []byte(`{"a":1}`),
+		"total":     []byte("3"),
+	}
+	if err := s.DecodeRow(row); err != nil {
+		t.Fatalf("DecodeRow() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"id":        int64(7),
+		"user_name": "a",
+		"enabled":   true,
+		"extra":     map[string]interface{}{"a": float64(1)},
+		"total":     "3", // 非模型字段转为string
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("DecodeRow() = %v, want %v", row, want)
+	}
+
+	if err := s.DecodeRow(map[string]interface{}{"id": []byte("x")}); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("DecodeRow() error = %v, want ErrInvalidValue", err)
+	}
+}
@@ -0,0 +1,61 @@
+package schema
+
+import "fmt"
+
+// FulltextIndex 全文索引，同名的字段组成一个索引，列按照字段定义的顺序排列
+type FulltextIndex struct {
+	Name    string
+	Parser  string // 分词器，目前仅mysql支持，如：ngram
+	Columns []string
+}
+
+// FulltextIndexes 获取模型中定义的全文索引
+func (schema *Schema) FulltextIndexes() []*FulltextIndex {
+	var indexes []*FulltextIndex
+	indexMap := make(map[string]*FulltextIndex)
+	for _, field := range schema.Fields {
+		if field.Fulltext == "" {
+			continue
+		}
+		index, ok := indexMap[field.Fulltext]
+		if !ok {
+			index = &FulltextIndex{Name: field.Fulltext}
+			indexMap[field.Fulltext] = index
+			indexes = append(indexes, index)
+		}
+		index.Parser = field.Parser
+		index.Columns = append(index.Columns, field.Column)
+	}
+	return indexes
+}
+
+func (field *Field) validateFulltext() error {
+	if field.Fulltext == "" {
+		if field.Parser != "" {
+			return fmt.Errorf("%w: field %s parser requires fulltext", ErrInvalidField, field.Name)
+		}
+		return nil
+	}
+	if field.Type != "string" && field.Type != "text" {
+		return fmt.Errorf("%w: fulltext field %s must be string or text", ErrInvalidField, field.Name)
+	}
+	return nil
+}
+
+// validateFulltextIndexes 同一个全文索引中的字段分词器必须一致
+func (schema *Schema) validateFulltextIndexes() error {
+	parsers := make(map[string]string)
+	for _, field := range schema.Fields {
+		if err := field.validateFulltext(); err != nil {
+			return err
+		}
+		if field.Fulltext == "" {
+			continue
+		}
+		if parser, ok := parsers[field.Fulltext]; ok && parser != field.Parser {
+			return fmt.Errorf("%w: fulltext index %s has different parsers", ErrInvalidField, field.Fulltext)
+		}
+		parsers[field.Fulltext] = field.Parser
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSchema_FulltextIndexes(t *testing.T) {
+	s := Parse(`{
+  "code": "article",
+  "fields": [
+    {"name": "id", "type": "ID"},
+    {"name": "title", "type": "string", "fulltext": "FTS_CONTENT", "parser": "ngram"},
+    {"name": "summary", "type": "string", "fulltext": true},
+    {"name": "content", "type": "text", "fulltext": "FTS_CONTENT", "parser": "ngram"}
+  ]
+}`)
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := []*FulltextIndex{
+		{Name: "FTS_CONTENT", Parser: "ngram", Columns: []string{"title", "content"}},
+		{Name: "FTS_ARTICLE_SUMMARY", Columns: []string{"summary"}},
+	}
+	if got := s.FulltextIndexes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FulltextIndexes() = %v, want %v", got, want)
+	}
+
+	invalid := []string{
+		`{"code": "a", "fields": [{"name": "age", "type": "int", "fulltext": true}]}`,
+		`{"code": "a", "fields": [{"name": "title", "type": "string", "parser": "ngram"}]}`,
+		`{"code": "a", "fields": [{"name": "title", "type": "string", "fulltext": "FTS", "parser": "ngram"}, {"name": "content", "type": "text", "fulltext": "FTS"}]}`,
+	}
+	for _, definition := range invalid {
+		if err := Parse(definition).Validate(); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("Validate() error = %v, want ErrInvalidField", err)
+		}
+	}
+}
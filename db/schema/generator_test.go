@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestField_Generator(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     string
+		valueType string
+		wantErr   bool
+	}{
+		{"snowflake", `{"name": "id", "type": "ID", "generator": "snowflake"}`, "id", false},
+		{"ulid", `{"name": "id", "type": "ID", "generator": "ulid"}`, "string", false},
+		{"uuid", `{"name": "id", "type": "ID", "generator": "uuid"}`, "string", false},
+		{"未注册的生成器", `{"name": "id", "type": "ID", "generator": "unknown"}`, "id", true},
+		{"非主键字段", `{"name": "code", "type": "string", "generator": "ulid"}`, "string", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Parse(`{"code": "a", "fields": [` + tt.field + `]}`)
+			err := s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidField) {
+				t.Errorf("Validate() error = %v, want ErrInvalidField", err)
+			}
+			if got := s.Fields[0].ValueType(); got != tt.valueType {
+				t.Errorf("ValueType() = %v, want %v", got, tt.valueType)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Index 普通索引，同名的字段组成一个索引，列按照字段定义的顺序排列
+type Index struct {
+	Name    string
+	Columns []string
+}
+
+// Indexes 获取模型中定义的普通索引
+func (schema *Schema) Indexes() []*Index {
+	var indexes []*Index
+	indexMap := make(map[string]*Index)
+	for _, field := range schema.Fields {
+		if field.Index == "" {
+			continue
+		}
+		index, ok := indexMap[field.Index]
+		if !ok {
+			index = &Index{Name: field.Index}
+			indexMap[field.Index] = index
+			indexes = append(indexes, index)
+		}
+		index.Columns = append(index.Columns, field.Column)
+	}
+	return indexes
+}
+
+// validate 中间表的其他列不能与关联的列重名，也不能是主键
+func (pivot *Pivot) validate(relationName string) error {
+	if pivot.LocalKey == pivot.ForeignKey {
+		return fmt.Errorf("%w: relation %s pivot foreign_key and local_key are the same", ErrInvalidRelation, relationName)
+	}
+	columns := map[string]bool{pivot.LocalKey: true, pivot.ForeignKey: true}
+	for _, field := range pivot.Fields {
+		if !IsValidType(field.Type) {
+			return fmt.Errorf("%w: pivot field %s has invalid type %q", ErrInvalidField, field.Name, field.Type)
+		}
+		if field.IsPrimaryKey || field.Generator != "" || field.IsVersion {
+			return fmt.Errorf("%w: pivot field %s can not be a primary key or version", ErrInvalidField, field.Name)
+		}
+		if columns[field.Column] {
+			return fmt.Errorf("%w: pivot field %s is duplicated", ErrInvalidField, field.Name)
+		}
+		columns[field.Column] = true
+	}
+	return nil
+}
+
+// PivotSchema 多对多关系中间表的模式，local为本模型，related为关联模型
+// 本模型及关联模型的主键组成联合主键，关联模型的主键列另建索引，用于反向查询
+func (relation *Relation) PivotSchema(local, related *Schema) (*Schema, error) {
+	if relation.Type != RELATION_MANY_TO_MANY || relation.Pivot == nil {
+		return nil, fmt.Errorf("%w: relation %s is not manyToMany", ErrInvalidRelation, relation.Name)
+	}
+	localKey, foreignKey := local.PrimaryKey(), related.PrimaryKey()
+	if localKey == nil || foreignKey == nil {
+		return nil, fmt.Errorf("%w: relation %s requires primary keys on both models", ErrInvalidRelation, relation.Name)
+	}
+	pivot := relation.Pivot
+	schema := &Schema{
+		Name:        pivot.Table,
+		TableName:   pivot.Table,
+		fieldMap:    make(map[string]*Field),
+		columnMap:   make(map[string]*Field),
+		queryMap:    make(map[string]*Query),
+		relationMap: make(map[string]*Relation),
+	}
+	schema.addField(pivotKey(localKey, pivot.LocalKey))
+	foreign := pivotKey(foreignKey, pivot.ForeignKey)
+	foreign.Index = strings.ToUpper(fmt.Sprintf("IDX_%s_%s", pivot.Table, pivot.ForeignKey))
+	schema.addField(foreign)
+	for _, field := range pivot.Fields {
+		schema.addField(field)
+	}
+	return schema, nil
+}
+
+// pivotKey 中间表中引用主键的列，类型与主键的值类型相同，不使用生成器
+func pivotKey(pk *Field, column string) *Field {
+	typ := pk.ValueType()
+	if typ == "id" {
+		typ = "int64"
+	}
+	return &Field{
+		Name:         column,
+		Column:       column,
+		Type:         typ,
+		Length:       pk.Length,
+		IsPrimaryKey: true,
+		NotNull:      true,
+	}
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRelation_PivotSchema(t *testing.T) {
+	member := Parse(`{"code": "member", "fields": [{"name": "id", "type": "ID"}], "relations": [
+		{"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id",
+			"fields": [{"name": "sort", "type": "int", "default": "0"}]}},
+		{"name": "role", "type": "belongsTo", "model": "role", "field": "id"}
+	]}`)
+	if err := member.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tag := Parse(`{"code": "tag", "fields": [{"name": "id", "type": "ID", "generator": "ulid"}]}`)
+
+	pivot, err := member.GetRelation("tags").PivotSchema(member, tag)
+	if err != nil {
+		t.Fatalf("PivotSchema() error = %v", err)
+	}
+	var columns, types []string
+	for _, field := range pivot.Fields {
+		columns = append(columns, field.Column)
+		types = append(types, field.Type)
+	}
+	if want := []string{"member_id", "tag_id", "sort"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("PivotSchema() columns = %v, want %v", columns, want)
+	}
+	if want := []string{"int64", "string", "int"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("PivotSchema() types = %v, want %v", types, want)
+	}
+	if !pivot.Fields[0].IsPrimaryKey || !pivot.Fields[1].IsPrimaryKey || pivot.Fields[2].IsPrimaryKey {
+		t.Errorf("PivotSchema() primary keys are not member_id and tag_id")
+	}
+	if want := []*Index{{Name: "IDX_MEMBER_TAG_TAG_ID", Columns: []string{"tag_id"}}}; !reflect.DeepEqual(pivot.Indexes(), want) {
+		t.Errorf("Indexes() = %v, want %v", pivot.Indexes(), want)
+	}
+
+	if _, err = member.GetRelation("role").PivotSchema(member, tag); !errors.Is(err, ErrInvalidRelation) {
+		t.Errorf("PivotSchema() of belongsTo error = %v, want ErrInvalidRelation", err)
+	}
+
+	invalid := []string{
+		`{"name": "member_id", "type": "int64"}`,
+		`{"name": "sort", "type": "unknown"}`,
+		`{"name": "id", "type": "ID"}`,
+	}
+	for _, field := range invalid {
+		s := Parse(`{"code": "member", "fields": [{"name": "id", "type": "ID"}], "relations": [
+			{"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id", "fields": [` + field + `]}}
+		]}`)
+		if err := s.Validate(); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("Validate() with pivot field %s error = %v, want ErrInvalidField", field, err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+var (
+	ErrInvalidQuery      error = errors.New("invalid query")
+	ErrInvalidQueryParam error = errors.New("invalid query param")
+)
+
+// sql中的命名参数，如：:deptId，"::"为转义的冒号不作为参数
+var namedParamRegexp = regexp.MustCompile(`(^|[^:]):([A-Za-z_][A-Za-z0-9_]*)`)
+
+// 条件模板中的参数占位符，值必须完整为 :参数名
+var placeholderRegexp = regexp.MustCompile(`^:([A-Za-z_][A-Za-z0-9_]*)$`)
+
+// QueryParam 自定义查询的参数
+type QueryParam struct {
+	Name     string
+	Label    string
+	Type     string
+	Array    bool // 参数值为数组，用于in查询
+	Required bool
+	Default  interface{}
+}
+
+// Query 模型json中定义的自定义查询，SQL与Where二选一
+// SQL为带命名参数的sql语句，如：SELECT * FROM user WHERE dept_id = :deptId
+// Where为条件模板，与Find的条件格式相同，值为 :参数名 时会被替换为参数值，如：{"dept_id": ":deptId"}
+type Query struct {
+	Name   string
+	Label  string
+	SQL    string
+	Where  map[string]interface{}
+	Fields []string
+	Params []*QueryParam
+}
+
+func parseQuery(q gjson.Result) *Query {
+	query := &Query{
+		Name:  q.Get("name").String(),
+		Label: q.Get("label").String(),
+		SQL:   strings.TrimSpace(q.Get("sql").String()),
+	}
+	if where, ok := q.Get("where").Value().(map[string]interface{}); ok {
+		query.Where = where
+	}
+	for _, f := range q.Get("fields").Array() {
+		query.Fields = append(query.Fields, f.String())
+	}
+	for _, p := range q.Get("params").Array() {
+		query.Params = append(query.Params, &QueryParam{
+			Name:     p.Get("name").String(),
+			Label:    p.Get("label").String(),
+			Type:     strings.ToLower(p.Get("type").String()),
+			Array:    p.Get("array").Bool(),
+			Required: p.Get("required").Bool(),
+			Default:  p.Get("default").Value(),
+		})
+	}
+	return query
+}
+
+// GetParam 获取查询参数定义
+func (query *Query) GetParam(name string) *QueryParam {
+	for _, p := range query.Params {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (query *Query) validate(schema *Schema) error {
+	if query.Name == "" {
+		return fmt.Errorf("%w: query name is empty", ErrInvalidQuery)
+	}
+	if (query.SQL == "") == (query.Where == nil) {
+		return fmt.Errorf("%w: query %s must define exactly one of sql and where", ErrInvalidQuery, query.Name)
+	}
+
+	params := make(map[string]bool, len(query.Params))
+	for _, p := range query.Params {
+		if p.Name == "" {
+			return fmt.Errorf("%w: query %s has a param without name", ErrInvalidQuery, query.Name)
+		}
+		if params[p.Name] {
+			return fmt.Errorf("%w: query %s param %s is duplicated", ErrInvalidQuery, query.Name, p.Name)
+		}
+		if !IsValidType(p.Type) {
+			return fmt.Errorf("%w: query %s param %s has invalid type %q", ErrInvalidQuery, query.Name, p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := p.convert(p.Default); err != nil {
+				return fmt.Errorf("%w: query %s param %s default: %v", ErrInvalidQuery, query.Name, p.Name, err)
+			}
+		}
+		params[p.Name] = true
+	}
+
+	for _, f := range query.Fields {
+		if !schema.hasField(f) {
+			return fmt.Errorf("%w: query %s selects unknown field %s", ErrInvalidQuery, query.Name, f)
+		}
+	}
+
+	// 所有引用的参数必须已声明
+	var refs []string
+	if query.SQL != "" {
+		for _, m := range namedParamRegexp.FindAllStringSubmatch(query.SQL, -1) {
+			refs = append(refs, m[2])
+		}
+	} else {
+		var err error
+		refs, err = schema.templateRefs(query.Where)
+		if err != nil {
+			return fmt.Errorf("%w: query %s: %v", ErrInvalidQuery, query.Name, err)
+		}
+	}
+	for _, ref := range refs {
+		if !params[ref] {
+			return fmt.Errorf("%w: query %s references undeclared param %s", ErrInvalidQuery, query.Name, ref)
+		}
+	}
+	return nil
+}
+
+// hasField 判断字段名或列名是否属于模型
+func (schema *Schema) hasField(name string) bool {
+	return schema.GetField(name) != nil || schema.GetFieldByColumn(util.ToSnake(name)) != nil
+}
+
+// templateRefs 检查条件模板中的字段，并返回模板引用的参数名
+func (schema *Schema) templateRefs(where map[string]interface{}) ([]string, error) {
+	var refs []string
+	for k, v := range where {
+		// $开头的为特殊操作符，如$or/$limit，不对应字段
+		if !strings.HasPrefix(k, "$") {
+			column := strings.SplitN(k, " ", 2)[0]
+			if !schema.hasField(column) {
+				return nil, fmt.Errorf("unknown field %s in where", column)
+			}
+		}
+		// $or/$and/$having为嵌套条件
+		if sub, ok := v.(map[string]interface{}); ok {
+			subRefs, err := schema.templateRefs(sub)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, subRefs...)
+			continue
+		}
+		refs = append(refs, placeholders(v)...)
+	}
+	return refs, nil
+}
+
+func placeholders(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		if m := placeholderRegexp.FindStringSubmatch(t); m != nil {
+			return []string{m[1]}
+		}
+	case []interface{}:
+		var refs []string
+		for _, e := range t {
+			refs = append(refs, placeholders(e)...)
+		}
+		return refs
+	}
+	return nil
+}
+
+func (p *QueryParam) convert(v interface{}) (interface{}, error) {
+	if !p.Array {
+		return Convert(p.Type, v)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("%w: %s should be an array", ErrInvalidQueryParam, p.Name)
+	}
+	values := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		value, err := Convert(p.Type, rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// BindParams 按照参数定义处理传入的参数：应用默认值、检查必填、转换为声明的类型，未声明的参数会被忽略
+func (query *Query) BindParams(params map[string]interface{}) (map[string]interface{}, error) {
+	bound := make(map[string]interface{}, len(query.Params))
+	for _, p := range query.Params {
+		v, ok := params[p.Name]
+		if !ok || v == nil {
+			v = p.Default
+		}
+		if v == nil {
+			if p.Required {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidQueryParam, p.Name)
+			}
+			bound[p.Name] = nil
+			continue
+		}
+		value, err := p.convert(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidQueryParam, p.Name, err)
+		}
+		bound[p.Name] = value
+	}
+	return bound, nil
+}
+
+// RenderWhere 将条件模板中的参数占位符替换为参数值，返回新的条件
+func (query *Query) RenderWhere(params map[string]interface{}) map[string]interface{} {
+	return renderTemplate(query.Where, params)
+}
+
+func renderTemplate(where map[string]interface{}, params map[string]interface{}) map[string]interface{} {
+	rendered := make(map[string]interface{}, len(where))
+	for k, v := range where {
+		rendered[k] = renderValue(v, params)
+	}
+	return rendered
+}
+
+func renderValue(v interface{}, params map[string]interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		if m := placeholderRegexp.FindStringSubmatch(t); m != nil {
+			return params[m[1]]
+		}
+	case []interface{}:
+		values := make([]interface{}, 0, len(t))
+		for _, e := range t {
+			r := renderValue(e, params)
+			// 数组参数展开到in的列表中
+			if arr, ok := r.([]interface{}); ok {
+				values = append(values, arr...)
+			} else {
+				values = append(values, r)
+			}
+		}
+		return values
+	case map[string]interface{}:
+		return renderTemplate(t, params)
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const queryDef = `{
+  "code": "user",
+  "name": "用户",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "用户名", "name": "username", "type": "string", "length": 20},
+    {"label": "部门", "name": "deptId", "type": "int64"},
+    {"label": "状态", "name": "status", "type": "int"}
+  ],
+  "queries": %s
+}`
+
+func parseWithQueries(queries string) *Schema {
+	return Parse(fmt.Sprintf(queryDef, queries))
+}
+
+func TestSchema_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries string
+		wantErr bool
+	}{
+		{
+			"sql查询",
+			`[{"name": "activeByDept", "sql": "SELECT * FROM user WHERE dept_id = :deptId AND status = :status",
+			   "params": [{"name": "deptId", "type": "int64", "required": true}, {"name": "status", "type": "int", "default": 1}]}]`,
+			false,
+		},
+		{
+			"条件模板查询",
+			`[{"name": "byIds", "where": {"id in": ":ids", "$or": {"username like": ":name", "status": 1}, "$limit": 10},
+			   "fields": ["id", "username"], "params": [{"name": "ids", "type": "id", "array": true}, {"name": "name", "type": "string"}]}]`,
+			false,
+		},
+		{
+			"未声明参数",
+			`[{"name": "q", "sql": "SELECT * FROM user WHERE dept_id = :deptId"}]`,
+			true,
+		},
+		{
+			"转义的冒号不是参数",
+			`[{"name": "q", "sql": "SELECT id::text FROM user"}]`,
+			false,
+		},
+		{
+			"参数类型错误",
+			`[{"name": "q", "sql": "SELECT * FROM user WHERE id = :id", "params": [{"name": "id", "type": "long"}]}]`,
+			true,
+		},
+		{
+			"默认值与类型不符",
+			`[{"name": "q", "sql": "SELECT * FROM user WHERE id = :id", "params": [{"name": "id", "type": "int", "default": "abc"}]}]`,
+			true,
+		},
+		{
+			"sql与where同时定义",
+			`[{"name": "q", "sql": "SELECT * FROM user", "where": {"id": 1}}]`,
+			true,
+		},
+		{
+			"条件模板中的字段不存在",
+			`[{"name": "q", "where": {"age >": 18}}]`,
+			true,
+		},
+		{
+			"查询字段不存在",
+			`[{"name": "q", "where": {"id": 1}, "fields": ["age"]}]`,
+			true,
+		},
+		{
+			"查询名称重复",
+			`[{"name": "q", "sql": "SELECT * FROM user"}, {"name": "q", "sql": "SELECT * FROM user"}]`,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseWithQueries(tt.queries).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("Validate() error = %v, want ErrInvalidQuery", err)
+			}
+		})
+	}
+}
+
+func TestQuery_BindParams(t *testing.T) {
+	s := parseWithQueries(`[{"name": "q", "where": {"dept_id": ":deptId", "status": ":status", "id in": ":ids"},
+	  "params": [{"name": "deptId", "type": "int64", "required": true}, {"name": "status", "type": "int", "default": 1},
+	             {"name": "ids", "type": "id", "array": true}]}]`)
+	q := s.GetQuery("q")
+	if q == nil {
+		t.Fatal("GetQuery() = nil")
+	}
+
+	if _, err := q.BindParams(map[string]interface{}{}); !errors.Is(err, ErrInvalidQueryParam) {
+		t.Errorf("BindParams() missing required error = %v", err)
+	}
+	if _, err := q.BindParams(map[string]interface{}{"deptId": "x"}); !errors.Is(err, ErrInvalidQueryParam) {
+		t.Errorf("BindParams() invalid value error = %v", err)
+	}
+
+	bound, err := q.BindParams(map[string]interface{}{"deptId": float64(3), "ids": []int{1, 2}})
+	if err != nil {
+		t.Fatalf("BindParams() error = %v", err)
+	}
+	want := map[string]interface{}{"deptId": int64(3), "status": int64(1), "ids": []interface{}{int64(1), int64(2)}}
+	if !reflect.DeepEqual(bound, want) {
+		t.Errorf("BindParams() = %v, want %v", bound, want)
+	}
+
+	where := q.RenderWhere(bound)
+	wantWhere := map[string]interface{}{"dept_id": int64(3), "status": int64(1), "id in": []interface{}{int64(1), int64(2)}}
+	if !reflect.DeepEqual(where, wantWhere) {
+		t.Errorf("RenderWhere() = %v, want %v", where, wantWhere)
+	}
+}
@@ -0,0 +1,134 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+const (
+	RELATION_BELONGS_TO   = "belongsTo"
+	RELATION_HAS_ONE      = "hasOne"
+	RELATION_HAS_MANY     = "hasMany"
+	RELATION_MANY_TO_MANY = "manyToMany"
+)
+
+// 删除本模型数据时对关联数据的处理
+const (
+	ON_DELETE_CASCADE  = "cascade"  // 删除关联数据，关联模型为软删除时设置deleted_at
+	ON_DELETE_SET_NULL = "setNull"  // 关联数据中本模型id的列设置为NULL
+	ON_DELETE_RESTRICT = "restrict" // 有关联数据时不允许删除
+	ON_DELETE_DETACH   = "detach"   // 删除多对多关系中间表中的数据
+)
+
+var (
+	ErrInvalidRelation error = errors.New("invalid relation")
+)
+
+// Pivot 多对多关系的中间表
+type Pivot struct {
+	Table      string
+	ForeignKey string   // 中间表中关联模型id的列
+	LocalKey   string   // 中间表中本模型id的列
+	Fields     []*Field // 中间表中的其他列，如：排序、添加时间
+}
+
+// Relation 模型之间的关系
+// belongsTo: Field为本模型中关联模型id的列
+// hasOne/hasMany: Field为关联模型中本模型id的列
+// manyToMany: 通过Pivot中间表关联
+// OnDelete为删除本模型数据时对关联数据的处理，为空时不处理；Constraint为是否在关联模型的表上建立外键约束，仅hasOne/hasMany可用
+type Relation struct {
+	Name       string
+	Type       string
+	Model      string
+	Field      string
+	Pivot      *Pivot
+	OnDelete   string
+	Constraint bool
+}
+
+func parseRelation(r gjson.Result) *Relation {
+	relation := &Relation{
+		Name:  r.Get("name").String(),
+		Type:  r.Get("type").String(),
+		Model: r.Get("model").String(),
+		Field: util.ToSnake(r.Get("field").String()),
+	}
+	relation.OnDelete = r.Get("onDelete").String()
+	relation.Constraint = r.Get("constraint").Bool()
+	if pivot := r.Get("pivot"); pivot.Exists() {
+		relation.Pivot = &Pivot{
+			Table:      util.ToSnake(pivot.Get("table").String()),
+			ForeignKey: util.ToSnake(pivot.Get("foreign_key").String()),
+			LocalKey:   util.ToSnake(pivot.Get("local_key").String()),
+		}
+		table := relation.Pivot.Table
+		for _, f := range pivot.Get("fields").Array() {
+			relation.Pivot.Fields = append(relation.Pivot.Fields, parseField(table, f))
+		}
+	}
+	return relation
+}
+
+// Alias 关系在查询中使用的表别名，即蛇形命名的关系名称
+func (relation *Relation) Alias() string {
+	return util.ToSnake(relation.Name)
+}
+
+// IsToMany 是否为一对多或多对多关系，关联查询时一条记录可能对应多条关联记录
+func (relation *Relation) IsToMany() bool {
+	return relation.Type == RELATION_HAS_MANY || relation.Type == RELATION_MANY_TO_MANY
+}
+
+func (relation *Relation) validate(schema *Schema) error {
+	if relation.Name == "" || relation.Model == "" {
+		return fmt.Errorf("%w: relation name and model are required", ErrInvalidRelation)
+	}
+	switch relation.Type {
+	case RELATION_BELONGS_TO:
+		if schema.GetFieldByColumn(relation.Field) == nil {
+			return fmt.Errorf("%w: relation %s field %s not found", ErrInvalidRelation, relation.Name, relation.Field)
+		}
+	case RELATION_HAS_ONE, RELATION_HAS_MANY:
+		// 字段属于关联模型，注册时关联模型可能还未注册，使用时再检查
+		if relation.Field == "" {
+			return fmt.Errorf("%w: relation %s field is required", ErrInvalidRelation, relation.Name)
+		}
+	case RELATION_MANY_TO_MANY:
+		if relation.Pivot == nil || relation.Pivot.Table == "" || relation.Pivot.ForeignKey == "" || relation.Pivot.LocalKey == "" {
+			return fmt.Errorf("%w: relation %s pivot table, foreign_key and local_key are required", ErrInvalidRelation, relation.Name)
+		}
+		if err := relation.Pivot.validate(relation.Name); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: relation %s has invalid type %q", ErrInvalidRelation, relation.Name, relation.Type)
+	}
+	return relation.validateOnDelete()
+}
+
+// validateOnDelete 一对一、一对多关系可以级联删除、置空或限制删除，多对多关系可以解除关联或限制删除
+func (relation *Relation) validateOnDelete() error {
+	var allowed []string
+	switch relation.Type {
+	case RELATION_HAS_ONE, RELATION_HAS_MANY:
+		allowed = []string{ON_DELETE_CASCADE, ON_DELETE_SET_NULL, ON_DELETE_RESTRICT}
+	case RELATION_MANY_TO_MANY:
+		allowed = []string{ON_DELETE_DETACH, ON_DELETE_RESTRICT}
+	}
+	if relation.Constraint && relation.Type != RELATION_HAS_ONE && relation.Type != RELATION_HAS_MANY {
+		return fmt.Errorf("%w: relation %s constraint is only allowed for hasOne and hasMany", ErrInvalidRelation, relation.Name)
+	}
+	if relation.OnDelete == "" {
+		return nil
+	}
+	for _, onDelete := range allowed {
+		if relation.OnDelete == onDelete {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: relation %s of type %s has invalid onDelete %q", ErrInvalidRelation, relation.Name, relation.Type, relation.OnDelete)
+}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRelation_OnDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		relation string
+		wantErr  bool
+	}{
+		{"一对多级联删除", `{"name": "posts", "type": "hasMany", "model": "post", "field": "member_id", "onDelete": "cascade", "constraint": true}`, false},
+		{"一对一置空", `{"name": "profile", "type": "hasOne", "model": "profile", "field": "member_id", "onDelete": "setNull"}`, false},
+		{"多对多解除关联", `{"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id"}, "onDelete": "detach"}`, false},
+		{"多对多限制删除", `{"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id"}, "onDelete": "restrict"}`, false},
+		{"多对多不能级联删除", `{"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id"}, "onDelete": "cascade"}`, true},
+		{"一对多不能解除关联", `{"name": "posts", "type": "hasMany", "model": "post", "field": "member_id", "onDelete": "detach"}`, true},
+		{"属于关系不能设置", `{"name": "role", "type": "belongsTo", "model": "role", "field": "role_id", "onDelete": "cascade"}`, true},
+		{"未知的处理", `{"name": "posts", "type": "hasMany", "model": "post", "field": "member_id", "onDelete": "drop"}`, true},
+		{"多对多不能建立约束", `{"name": "tags", "type": "manyToMany", "model": "tag", "pivot": {"table": "member_tag", "foreign_key": "tag_id", "local_key": "member_id"}, "constraint": true}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Parse(`{"code": "member", "fields": [{"name": "id", "type": "ID"}, {"name": "roleId", "type": "int64"}], "relations": [` + tt.relation + `]}`)
+			err := s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRelation) {
+				t.Errorf("Validate() error = %v, want ErrInvalidRelation", err)
+			}
+		})
+	}
+}
+
+func TestSchema_SoftDeleteColumn(t *testing.T) {
+	s := Parse(`{"code": "a", "options": {"softDelete": true}, "fields": [{"name": "id", "type": "ID"}, {"name": "deletedAt", "type": "datetime"}]}`)
+	if got := s.SoftDeleteColumn(); got != "deleted_at" {
+		t.Errorf("SoftDeleteColumn() = %q, want deleted_at", got)
+	}
+	s = Parse(`{"code": "a", "options": {"softDelete": true}, "fields": [{"name": "id", "type": "ID"}]}`)
+	if got := s.SoftDeleteColumn(); got != "" {
+		t.Errorf("SoftDeleteColumn() without deleted_at = %q, want empty", got)
+	}
+}
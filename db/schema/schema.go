@@ -1,13 +1,19 @@
 package schema
 
 import (
+	"errors"
 	"fmt"
 	"github.com/tidwall/gjson"
+	"github.com/yaochi-tech/lingquan-core-go/idgen"
 	"github.com/yaochi-tech/lingquan-core-go/util"
 
 	"strings"
 )
 
+var (
+	ErrInvalidField error = errors.New("invalid field")
+)
+
 type Field struct {
 	Label        string
 	Name         string
@@ -21,25 +27,103 @@ type Field struct {
 	NotNull      bool
 	Index        string
 	Unique       string
+	Fulltext     string // 全文索引名称
+	Parser       string // 全文索引的分词器
+	Generator    string // 主键生成器，如：snowflake、ulid、uuid或自定义注册的生成器
+	IsVersion    bool   // 乐观锁的版本号，更新时校验并加1
 	Length       uint
 	Precision    uint
 	Scale        uint
 }
 
+// ValueType 字段值的类型，使用字符串生成器（如：ulid、uuid）的主键为string，其他字段为Type
+func (field *Field) ValueType() string {
+	if field.Generator != "" {
+		if generator, ok := idgen.Get(field.Generator); ok && generator.Type() == idgen.TYPE_STRING {
+			return "string"
+		}
+	}
+	return field.Type
+}
+
 type Schema struct {
-	Definition string
-	Name       string
-	TableName  string
-	Comment    string
-	Fields     []*Field
-	FieldNames []string
-	fieldMap   map[string]*Field
+	Definition  string
+	Name        string
+	TableName   string
+	Comment     string
+	Fields      []*Field
+	FieldNames  []string
+	Queries     []*Query
+	Relations   []*Relation
+	SoftDelete  bool // 是否软删除，模型中需要定义deletedAt字段
+	fieldMap    map[string]*Field
+	columnMap   map[string]*Field
+	queryMap    map[string]*Query
+	relationMap map[string]*Relation
 }
 
 func (schema *Schema) GetField(name string) *Field {
 	return schema.fieldMap[name]
 }
 
+// GetFieldByColumn 根据列名获取字段
+func (schema *Schema) GetFieldByColumn(column string) *Field {
+	return schema.columnMap[column]
+}
+
+// GetRelation 获取模型关系，name为关系名称或其蛇形命名
+func (schema *Schema) GetRelation(name string) *Relation {
+	return schema.relationMap[util.ToSnake(name)]
+}
+
+// SoftDeleteColumn 软删除的列，模型为软删除且定义了deletedAt字段时为deleted_at，否则为空
+func (schema *Schema) SoftDeleteColumn() string {
+	if schema.SoftDelete && schema.GetFieldByColumn("deleted_at") != nil {
+		return "deleted_at"
+	}
+	return ""
+}
+
+// PrimaryKey 获取主键字段，没有主键时返回nil
+func (schema *Schema) PrimaryKey() *Field {
+	for _, field := range schema.Fields {
+		if field.IsPrimaryKey {
+			return field
+		}
+	}
+	return nil
+}
+
+// UniqueIndex 唯一索引，同名的字段组成一个索引，列按照字段定义的顺序排列
+type UniqueIndex struct {
+	Name    string
+	Columns []string
+}
+
+// UniqueIndexes 获取模型中定义的唯一索引
+func (schema *Schema) UniqueIndexes() []*UniqueIndex {
+	var indexes []*UniqueIndex
+	indexMap := make(map[string]*UniqueIndex)
+	for _, field := range schema.Fields {
+		if field.Unique == "" {
+			continue
+		}
+		index, ok := indexMap[field.Unique]
+		if !ok {
+			index = &UniqueIndex{Name: field.Unique}
+			indexMap[field.Unique] = index
+			indexes = append(indexes, index)
+		}
+		index.Columns = append(index.Columns, field.Column)
+	}
+	return indexes
+}
+
+// GetQuery 获取模型中定义的自定义查询
+func (schema *Schema) GetQuery(name string) *Query {
+	return schema.queryMap[name]
+}
+
 // RecordValues 获取按照模型字段顺序排列的字段数据
 func (schema *Schema) RecordValues(dest map[string]interface{}) []interface{} {
 	var fieldValues []interface{}
@@ -53,56 +137,151 @@ func (schema *Schema) RecordValues(dest map[string]interface{}) []interface{} {
 func Parse(definition string) *Schema {
 	dj := gjson.Parse(definition)
 	schema := &Schema{
-		Definition: definition,
-		Name:       dj.Get("code").String(),
-		TableName:  util.ToSnake(dj.Get("code").String()),
-		Comment:    dj.Get("comment").String(),
-		fieldMap:   make(map[string]*Field),
-	}
-
-	fields := dj.Get("fields").Array()
-	for _, f := range fields {
-		// name/type/label
-		field := new(Field)
-
-		field.Label = f.Get("label").String()
-		field.Name = f.Get("name").String()
-		field.Column = util.ToSnake(field.Name)
-		t := strings.ToLower(f.Get("type").String())
-		if t == "enum" {
-			field.Type = f.Get("enumType").String()
-			for _, enum := range f.Get("enum").Array() {
-				field.Enum = append(field.Enum, enum.String())
-			}
-		} else {
-			field.Type = t
+		Definition:  definition,
+		Name:        dj.Get("code").String(),
+		TableName:   util.ToSnake(dj.Get("code").String()),
+		Comment:     dj.Get("comment").String(),
+		fieldMap:    make(map[string]*Field),
+		columnMap:   make(map[string]*Field),
+		queryMap:    make(map[string]*Query),
+		relationMap: make(map[string]*Relation),
+	}
+
+	options := dj.Get("options")
+	schema.SoftDelete = options.Get("softDelete").Bool() || options.Get("softDeletes").Bool()
+
+	for _, f := range dj.Get("fields").Array() {
+		schema.addField(parseField(schema.TableName, f))
+	}
+
+	for _, r := range dj.Get("relations").Array() {
+		relation := parseRelation(r)
+		schema.Relations = append(schema.Relations, relation)
+		schema.relationMap[relation.Alias()] = relation
+	}
+
+	for _, q := range dj.Get("queries").Array() {
+		query := parseQuery(q)
+		schema.Queries = append(schema.Queries, query)
+		schema.queryMap[query.Name] = query
+	}
+
+	return schema
+}
+
+// parseField 解析字段定义，tableName用于生成默认的索引名称
+func parseField(tableName string, f gjson.Result) *Field {
+	// name/type/label
+	field := new(Field)
+
+	field.Label = f.Get("label").String()
+	field.Name = f.Get("name").String()
+	field.Column = util.ToSnake(field.Name)
+	t := strings.ToLower(f.Get("type").String())
+	if t == "enum" {
+		field.Type = f.Get("enumType").String()
+		for _, enum := range f.Get("enum").Array() {
+			field.Enum = append(field.Enum, enum.String())
 		}
-		field.Comment = f.Get("comment").String()
-		field.Default = f.Get("default_raw").String()
-		field.IsDefaultRaw = field.Default != ""
-		if !field.IsDefaultRaw {
-			field.Default = f.Get("default").String()
+	} else {
+		field.Type = t
+	}
+	field.Comment = f.Get("comment").String()
+	field.Default = f.Get("default_raw").String()
+	field.IsDefaultRaw = field.Default != ""
+	if !field.IsDefaultRaw {
+		field.Default = f.Get("default").String()
+	}
+	field.IsPrimaryKey = t == "id"
+	field.Generator = f.Get("generator").String()
+	field.NotNull = f.Get("required").Bool()
+	if idx := f.Get("index"); idx.IsBool() {
+		if idx.Bool() {
+			field.Index = strings.ToUpper(fmt.Sprintf("IDX_%s_%s", tableName, field.Column))
+		}
+	} else {
+		field.Index = idx.String()
+	}
+	if uni := f.Get("unique"); uni.IsBool() {
+		if uni.Bool() {
+			field.Unique = strings.ToUpper(fmt.Sprintf("UNI_%s_%s", tableName, field.Column))
+		}
+	} else {
+		field.Unique = uni.String()
+	}
+	if ft := f.Get("fulltext"); ft.IsBool() {
+		if ft.Bool() {
+			field.Fulltext = strings.ToUpper(fmt.Sprintf("FTS_%s_%s", tableName, field.Column))
+		}
+	} else {
+		field.Fulltext = ft.String()
+	}
+	field.Parser = f.Get("parser").String()
+	field.IsVersion = f.Get("version").Bool()
+	if field.IsVersion {
+		// 版本号不能为NULL，否则更新时无法校验及加1
+		field.NotNull = true
+		if field.Default == "" {
+			field.Default = "1"
 		}
-		field.IsPrimaryKey = t == "id"
-		field.NotNull = f.Get("required").Bool()
-		if idx := f.Get("index"); idx.IsBool() {
-			field.Index = strings.ToUpper(fmt.Sprintf("IDX_%s_%s", schema.TableName, field.Column))
-		} else {
-			field.Index = idx.String()
+	}
+	field.Length = uint(f.Get("length").Uint())
+	field.Precision = uint(f.Get("precision").Uint())
+	field.Scale = uint(f.Get("scale").Uint())
+
+	return field
+}
+
+// addField 添加字段
+func (schema *Schema) addField(field *Field) {
+	schema.Fields = append(schema.Fields, field)
+	schema.FieldNames = append(schema.FieldNames, field.Column)
+	schema.fieldMap[field.Name] = field
+	schema.columnMap[field.Column] = field
+}
+
+// Validate 校验模型定义中的字段类型、关系及自定义查询
+func (schema *Schema) Validate() error {
+	for _, field := range schema.Fields {
+		if !IsValidType(field.Type) {
+			return fmt.Errorf("%w: field %s has invalid type %q", ErrInvalidField, field.Name, field.Type)
 		}
-		if uni := f.Get("unique"); uni.IsBool() {
-			field.Unique = strings.ToUpper(fmt.Sprintf("UNI_%s_%s", schema.TableName, field.Column))
-		} else {
-			field.Unique = uni.String()
+		if field.Generator != "" {
+			if !field.IsPrimaryKey {
+				return fmt.Errorf("%w: field %s is not a primary key, generator is not allowed", ErrInvalidField, field.Name)
+			}
+			if _, ok := idgen.Get(field.Generator); !ok {
+				return fmt.Errorf("%w: field %s has unknown generator %q", ErrInvalidField, field.Name, field.Generator)
+			}
 		}
-		field.Length = uint(f.Get("length").Uint())
-		field.Precision = uint(f.Get("precision").Uint())
-		field.Scale = uint(f.Get("scale").Uint())
+	}
+	if err := schema.validateFulltextIndexes(); err != nil {
+		return err
+	}
+	if err := schema.validateVersion(); err != nil {
+		return err
+	}
 
-		schema.Fields = append(schema.Fields, field)
-		schema.FieldNames = append(schema.FieldNames, field.Column)
-		schema.fieldMap[field.Name] = field
+	relations := make(map[string]bool, len(schema.Relations))
+	for _, relation := range schema.Relations {
+		if relations[relation.Alias()] {
+			return fmt.Errorf("%w: relation %s is duplicated", ErrInvalidRelation, relation.Name)
+		}
+		relations[relation.Alias()] = true
+		if err := relation.validate(schema); err != nil {
+			return err
+		}
 	}
 
-	return schema
+	names := make(map[string]bool, len(schema.Queries))
+	for _, query := range schema.Queries {
+		if names[query.Name] {
+			return fmt.Errorf("%w: query %s is duplicated", ErrInvalidQuery, query.Name)
+		}
+		names[query.Name] = true
+		if err := query.validate(schema); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,19 +1,16 @@
 package schema
 
 import (
-	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
 	"testing"
 )
 
+// 注意：schema的测试不能导入dialect包，dialect依赖schema，导入后测试会因循环依赖无法编译
 func TestParse(t *testing.T) {
 
 	type args struct {
 		dest string
-		d    dialect.Dialect
 	}
 
-	mysqlDialect, _ := dialect.GetDialect("mysql")
-
 	fields := []*Field{
 		{
 			Label:        "主键",
@@ -356,7 +353,6 @@ func TestParse(t *testing.T) {
     }
   ]
 }`,
-				d: mysqlDialect,
 			},
 			want: &Schema{
 				Name:       "user",
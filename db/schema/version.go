@@ -0,0 +1,33 @@
+package schema
+
+import "fmt"
+
+// VersionField 获取乐观锁的版本号字段，没有时返回nil
+func (schema *Schema) VersionField() *Field {
+	for _, field := range schema.Fields {
+		if field.IsVersion {
+			return field
+		}
+	}
+	return nil
+}
+
+// validateVersion 版本号字段必须是整数类型，且每个模型最多一个
+func (schema *Schema) validateVersion() error {
+	var version *Field
+	for _, field := range schema.Fields {
+		if !field.IsVersion {
+			continue
+		}
+		switch field.Type {
+		case "int", "int64", "uint", "uint64":
+		default:
+			return fmt.Errorf("%w: version field %s must be an integer", ErrInvalidField, field.Name)
+		}
+		if version != nil {
+			return fmt.Errorf("%w: version fields %s and %s are duplicated", ErrInvalidField, version.Name, field.Name)
+		}
+		version = field
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSchema_VersionField(t *testing.T) {
+	s := Parse(`{"code": "a", "fields": [{"name": "id", "type": "ID"}, {"name": "version", "type": "int64", "version": true}]}`)
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if field := s.VersionField(); field == nil || field.Name != "version" || !field.NotNull || field.Default != "1" {
+		t.Errorf("VersionField() = %+v", field)
+	}
+
+	invalid := []string{
+		`{"code": "a", "fields": [{"name": "version", "type": "string", "version": true}]}`,
+		`{"code": "a", "fields": [{"name": "v1", "type": "int", "version": true}, {"name": "v2", "type": "int", "version": true}]}`,
+	}
+	for _, definition := range invalid {
+		if err := Parse(definition).Validate(); !errors.Is(err, ErrInvalidField) {
+			t.Errorf("Validate() error = %v, want ErrInvalidField", err)
+		}
+	}
+}
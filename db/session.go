@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Session 事务中的会话，提供与Engine相同的增删改查方法，所有操作在同一个事务中执行
+type Session struct {
+	engine *Engine
+	ctx    context.Context
+	tx     *sqlx.Tx
+	depth  int // 嵌套的层数，最外层事务为0
+}
+
+// Transaction 在事务中执行fn，fn返回nil时提交，返回错误时回滚并返回该错误；fn中panic时回滚后继续panic
+// fn中调用Session.Transaction为嵌套事务，使用保存点，如：
+//
+//	err := engine.Transaction(ctx, func(s *db.Session) error {
+//		if _, err := s.Insert("order", order); err != nil {
+//			return err
+//		}
+//		_, err := s.Update("stock", map[string]interface{}{"qty -": 1}, map[string]interface{}{"id": 1})
+//		return err
+//	})
+func (engine *Engine) Transaction(ctx context.Context, fn func(s *Session) error) error {
+	if engine.DB == nil {
+		return ErrEngineOffline
+	}
+	tx, err := engine.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err = fn(&Session{engine: engine, ctx: ctx, tx: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Transaction 嵌套事务，在保存点中执行fn，fn返回错误或panic时只回滚到保存点，外层事务可以继续执行
+func (s *Session) Transaction(fn func(s *Session) error) error {
+	d := s.engine.dialect
+	name := fmt.Sprintf("sp_%d", s.depth+1)
+	if _, err := s.tx.ExecContext(s.ctx, d.SavepointSQL(name)); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = s.tx.ExecContext(s.ctx, d.RollbackToSavepointSQL(name))
+			panic(p)
+		}
+	}()
+	if err := fn(&Session{engine: s.engine, ctx: s.ctx, tx: s.tx, depth: s.depth + 1}); err != nil {
+		if _, rollbackErr := s.tx.ExecContext(s.ctx, d.RollbackToSavepointSQL(name)); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint %s: %v)", err, name, rollbackErr)
+		}
+		return err
+	}
+	if release := d.ReleaseSavepointSQL(name); release != "" {
+		if _, err := s.tx.ExecContext(s.ctx, release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tx 会话使用的事务，可用于执行模型以外的语句
+func (s *Session) Tx() *sqlx.Tx {
+	return s.tx
+}
+
+// Context 会话的ctx
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// Insert 同Engine.Insert
+func (s *Session) Insert(name string, data ...map[string]interface{}) ([]interface{}, error) {
+	return s.engine.insert(s.ctx, name, data, s.tx)
+}
+
+// InsertReturning 同Engine.InsertReturning
+func (s *Session) InsertReturning(name string, data []map[string]interface{}) ([]map[string]interface{}, error) {
+	return s.engine.InsertReturningContext(s.ctx, name, data, s.tx)
+}
+
+// InsertGraph 同Engine.InsertGraph
+func (s *Session) InsertGraph(name string, data []map[string]interface{}) ([]interface{}, error) {
+	return s.engine.InsertGraphContext(s.ctx, name, data, s.tx)
+}
+
+// BulkInsert 同Engine.BulkInsert
+func (s *Session) BulkInsert(name string, data []map[string]interface{}, opts BulkInsertOptions) ([]ChunkResult, error) {
+	return s.engine.BulkInsertContext(s.ctx, name, data, opts, s.tx)
+}
+
+// Upsert 同Engine.Upsert
+func (s *Session) Upsert(name string, data []map[string]interface{}, conflictFields, updateFields []string) (int64, error) {
+	return s.engine.UpsertContext(s.ctx, name, data, conflictFields, updateFields, s.tx)
+}
+
+// Find 同Engine.Find，条件中可以使用$lock
+func (s *Session) Find(name string, namedCondition map[string]interface{}, selectFields []string) ([]map[string]interface{}, error) {
+	return s.engine.FindContext(s.ctx, name, namedCondition, selectFields, s.tx)
+}
+
+// FindOne 同Engine.FindOne
+func (s *Session) FindOne(name string, namedCondition map[string]interface{}, selectFields []string) (map[string]interface{}, error) {
+	return s.engine.FindOneContext(s.ctx, name, namedCondition, selectFields, s.tx)
+}
+
+// FindByID 同Engine.FindByID
+func (s *Session) FindByID(name string, id interface{}, selectFields []string) (map[string]interface{}, error) {
+	return s.engine.FindByIDContext(s.ctx, name, id, selectFields, s.tx)
+}
+
+// FindEach 同Engine.FindEach
+func (s *Session) FindEach(name string, namedCondition map[string]interface{}, selectFields []string, fn func(row map[string]interface{}) error) error {
+	return s.engine.FindEachContext(s.ctx, name, namedCondition, selectFields, fn, s.tx)
+}
+
+// Query 同Engine.Query
+func (s *Session) Query(name, queryName string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	return s.engine.query(s.ctx, name, queryName, params, s.tx)
+}
+
+// Update 同Engine.Update，超过$max_affected时返回错误，由Transaction回滚
+func (s *Session) Update(name string, data, namedCondition map[string]interface{}) (int64, error) {
+	return s.engine.UpdateContext(s.ctx, name, data, namedCondition, s.tx)
+}
+
+// UpdateReturning 同Engine.UpdateReturning
+func (s *Session) UpdateReturning(name string, data, namedCondition map[string]interface{}) ([]map[string]interface{}, error) {
+	return s.engine.UpdateReturningContext(s.ctx, name, data, namedCondition, s.tx)
+}
+
+// UpdateGraph 同Engine.UpdateGraph
+func (s *Session) UpdateGraph(name string, id interface{}, data map[string]interface{}, mode string) error {
+	return s.engine.UpdateGraphContext(s.ctx, name, id, data, mode, s.tx)
+}
+
+// BulkUpdate 同Engine.BulkUpdate
+func (s *Session) BulkUpdate(name string, data []map[string]interface{}, opts BulkUpdateOptions) (int64, error) {
+	return s.engine.BulkUpdateContext(s.ctx, name, data, opts, s.tx)
+}
+
+// UpdateDryRun 同Engine.UpdateDryRun
+func (s *Session) UpdateDryRun(name string, data, namedCondition map[string]interface{}) (int64, error) {
+	return s.engine.UpdateDryRunContext(s.ctx, name, data, namedCondition, s.tx)
+}
+
+// Delete 同Engine.Delete
+func (s *Session) Delete(name string, namedCondition map[string]interface{}) (int64, error) {
+	return s.engine.DeleteContext(s.ctx, name, namedCondition, s.tx)
+}
+
+// DeleteDryRun 同Engine.DeleteDryRun
+func (s *Session) DeleteDryRun(name string, namedCondition map[string]interface{}) (int64, error) {
+	return s.engine.DeleteDryRunContext(s.ctx, name, namedCondition, s.tx)
+}
+
+// Attach 同Engine.Attach
+func (s *Session) Attach(name string, id interface{}, relationName string, ids []interface{}, extra map[string]interface{}) (int64, error) {
+	return s.engine.AttachContext(s.ctx, name, id, relationName, ids, extra, s.tx)
+}
+
+// Detach 同Engine.Detach
+func (s *Session) Detach(name string, id interface{}, relationName string, ids []interface{}) (int64, error) {
+	return s.engine.DetachContext(s.ctx, name, id, relationName, ids, s.tx)
+}
+
+// Sync 同Engine.Sync
+func (s *Session) Sync(name string, id interface{}, relationName string, ids []interface{}, extra map[string]interface{}) (*SyncResult, error) {
+	return s.engine.SyncContext(s.ctx, name, id, relationName, ids, extra, s.tx)
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestEngine_TransactionOffline(t *testing.T) {
+	Convey("离线引擎不能开启事务", t, func() {
+		engine := newTestEngine(roleDef)
+		called := false
+		err := engine.Transaction(context.Background(), func(s *Session) error {
+			called = true
+			return nil
+		})
+		So(err, ShouldEqual, ErrEngineOffline)
+		So(called, ShouldBeFalse)
+	})
+}
+
+func TestEngine_TransactionStatements(t *testing.T) {
+	Convey("事务及保存点的语句顺序", t, func() {
+		engine, fake := newFakeEngine(roleDef)
+		ctx := context.Background()
+		failed := errors.New("failed")
+		update := func(s *Session, id int) error {
+			_, err := s.Update("role", map[string]interface{}{"name": "x"}, map[string]interface{}{"id": id})
+			return err
+		}
+
+		Convey("fn返回nil时提交，返回错误时回滚", func() {
+			So(engine.Transaction(ctx, func(s *Session) error { return update(s, 1) }), ShouldBeNil)
+			So(engine.Transaction(ctx, func(s *Session) error { return failed }), ShouldEqual, failed)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"UPDATE `role` SET `name`='x' WHERE (`id` = 1)",
+				"COMMIT",
+				"BEGIN",
+				"ROLLBACK",
+			})
+		})
+
+		Convey("嵌套事务成功时释放保存点", func() {
+			err := engine.Transaction(ctx, func(s *Session) error {
+				return s.Transaction(func(s *Session) error {
+					if err := update(s, 1); err != nil {
+						return err
+					}
+					return s.Transaction(func(s *Session) error { return update(s, 2) })
+				})
+			})
+			So(err, ShouldBeNil)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SAVEPOINT `sp_1`",
+				"UPDATE `role` SET `name`='x' WHERE (`id` = 1)",
+				"SAVEPOINT `sp_2`",
+				"UPDATE `role` SET `name`='x' WHERE (`id` = 2)",
+				"RELEASE SAVEPOINT `sp_2`",
+				"RELEASE SAVEPOINT `sp_1`",
+				"COMMIT",
+			})
+		})
+
+		Convey("嵌套事务失败时只回滚到保存点，外层继续并提交", func() {
+			err := engine.Transaction(ctx, func(s *Session) error {
+				err := s.Transaction(func(s *Session) error {
+					if err := update(s, 1); err != nil {
+						return err
+					}
+					return failed
+				})
+				if err != failed {
+					return errors.New("want failed")
+				}
+				return update(s, 2)
+			})
+			So(err, ShouldBeNil)
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SAVEPOINT `sp_1`",
+				"UPDATE `role` SET `name`='x' WHERE (`id` = 1)",
+				"ROLLBACK TO SAVEPOINT `sp_1`",
+				"UPDATE `role` SET `name`='x' WHERE (`id` = 2)",
+				"COMMIT",
+			})
+		})
+
+		Convey("嵌套事务中panic时回滚到保存点，外层回滚后继续panic", func() {
+			So(func() {
+				_ = engine.Transaction(ctx, func(s *Session) error {
+					return s.Transaction(func(s *Session) error { panic("boom") })
+				})
+			}, ShouldPanicWith, "boom")
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SAVEPOINT `sp_1`",
+				"ROLLBACK TO SAVEPOINT `sp_1`",
+				"ROLLBACK",
+			})
+		})
+
+		Convey("回滚到保存点失败时返回两个错误", func() {
+			fake.exec = func(sql string) (int64, error) {
+				if sql == "ROLLBACK TO SAVEPOINT `sp_1`" {
+					return 0, errors.New("lost")
+				}
+				return 1, nil
+			}
+			err := engine.Transaction(ctx, func(s *Session) error {
+				return s.Transaction(func(s *Session) error { return failed })
+			})
+			So(errors.Is(err, failed), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "lost")
+			So(fake.statements(), ShouldResemble, []string{
+				"BEGIN",
+				"SAVEPOINT `sp_1`",
+				"ROLLBACK TO SAVEPOINT `sp_1`",
+				"ROLLBACK",
+			})
+		})
+	})
+}
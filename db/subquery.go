@@ -0,0 +1,66 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+// SubQuery 以其他模型的查询作为条件的值，Model为模型名(code)，Field为查询的字段，Where为子查询的条件
+// 如：{"id in": SubQuery{Model: "order", Field: "userId", Where: map[string]interface{}{"amount >": 100}}}
+// 操作符为exists/not exists时为关联子查询，子查询的Field与条件中的字段相等，如：{"id exists": SubQuery{...}}
+type SubQuery struct {
+	Model string
+	Field string
+	Where map[string]interface{}
+}
+
+// resolveSubQueries 将条件中的SubQuery转换为方言的子查询，返回新的条件，子查询中的 关系名.字段 会连接关联表
+func (engine *Engine) resolveSubQueries(where map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(where))
+	for k, v := range where {
+		switch t := v.(type) {
+		case SubQuery:
+			sq, err := engine.subQuery(t)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = sq
+		case map[string]interface{}:
+			// $or/$and/$having中的嵌套条件
+			sub, err := engine.resolveSubQueries(t)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = sub
+		default:
+			resolved[k] = v
+		}
+	}
+	return resolved, nil
+}
+
+func (engine *Engine) subQuery(sq SubQuery) (dialect.SubQuery, error) {
+	s := engine.GetSchema(sq.Model)
+	if s == nil {
+		return dialect.SubQuery{}, fmt.Errorf("%w: %s", ErrSchemaNotRegistered, sq.Model)
+	}
+	if !strings.Contains(sq.Field, ".") && s.GetFieldByColumn(util.ToSnake(sq.Field)) == nil {
+		return dialect.SubQuery{}, fmt.Errorf("%w: field %s not found in model %s", schema.ErrInvalidField, sq.Field, sq.Model)
+	}
+	where, err := engine.resolveSubQueries(sq.Where)
+	if err != nil {
+		return dialect.SubQuery{}, err
+	}
+	joins, _, err := engine.resolveJoins(s, where, []string{sq.Field})
+	if err != nil {
+		return dialect.SubQuery{}, err
+	}
+	if len(joins) > 0 {
+		where[dialect.OP_JOIN] = joins
+	}
+	return dialect.SubQuery{Table: s.TableName, Field: sq.Field, Where: where}, nil
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"testing"
+)
+
+func TestEngine_resolveSubQueries(t *testing.T) {
+	Convey("子查询测试", t, func() {
+		engine := newTestEngine(roleDef, memberDef, postDef, tagDef)
+
+		buildSelect := func(name string, where map[string]interface{}) string {
+			where, err := engine.resolveSubQueries(where)
+			So(err, ShouldBeNil)
+			sql, _, err := engine.dialect.BuildSelect(engine.GetSchema(name).TableName, []string{"id"}, where)
+			So(err, ShouldBeNil)
+			return sql
+		}
+
+		Convey("in子查询", func() {
+			sql := buildSelect("member", map[string]interface{}{
+				"id in": SubQuery{Model: "post", Field: "memberId", Where: map[string]interface{}{"title like": "%go%"}},
+			})
+			So(sql, ShouldEqual, "SELECT `id` FROM `member` WHERE `id` IN "+
+				"(SELECT `post`.`member_id` FROM `post` WHERE (`post`.`title` LIKE BINARY '%go%'))")
+		})
+
+		Convey("子查询中的关联字段", func() {
+			sql := buildSelect("role", map[string]interface{}{
+				"$or": map[string]interface{}{
+					"id": SubQuery{Model: "member", Field: "roleId", Where: map[string]interface{}{"tags.name": "go"}},
+				},
+			})
+			So(sql, ShouldEqual, "SELECT `id` FROM `role` WHERE `id` IN "+
+				"(SELECT DISTINCT `member`.`role_id` FROM `member` "+
+				"LEFT JOIN `member_tag` AS `tags_pivot` ON (`tags_pivot`.`member_id` = `member`.`id`) "+
+				"LEFT JOIN `tag` AS `tags` ON (`tags`.`id` = `tags_pivot`.`tag_id`) "+
+				"WHERE (`tags`.`name` = 'go'))")
+		})
+
+		Convey("exists关联子查询", func() {
+			sql := buildSelect("member", map[string]interface{}{
+				"id exists": SubQuery{Model: "post", Field: "memberId"},
+			})
+			So(sql, ShouldEqual, "SELECT `id` FROM `member` WHERE EXISTS "+
+				"(SELECT 1 FROM `post` WHERE (`post`.`member_id` = `member`.`id`))")
+		})
+
+		Convey("模型或字段不存在", func() {
+			_, err := engine.resolveSubQueries(map[string]interface{}{"id": SubQuery{Model: "order", Field: "memberId"}})
+			So(err, ShouldWrap, ErrSchemaNotRegistered)
+			_, err = engine.resolveSubQueries(map[string]interface{}{"id": SubQuery{Model: "post", Field: "userId"}})
+			So(err, ShouldWrap, schema.ErrInvalidField)
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// InsertSQL 返回Insert将要执行的语句及参数，不会访问数据库，主键配置了生成器时语句中包含新生成的主键
+func (engine *Engine) InsertSQL(name string, data ...map[string]interface{}) (string, []interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", nil, err
+	}
+	sql, args, _, err := engine.buildInsert(s, data)
+	return sql, args, err
+}
+
+// buildInsert 生成插入语句，返回各行的主键
+func (engine *Engine) buildInsert(s *schema.Schema, data []map[string]interface{}) (string, []interface{}, []interface{}, error) {
+	rows, err := insertRows(s, data)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	rows, ids, err := fillIDs(s, rows)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	// BuildInsert会对data的key转换为蛇形命名，各行的列不同时补齐缺少的列
+	sql, args, err := engine.dialect.BuildInsert(s.TableName, normalizeRows(s, rows))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return sql, args, ids, nil
+}
+
+// FindSQL 返回Find将要执行的语句及参数，不会访问数据库
+func (engine *Engine) FindSQL(name string, namedCondition map[string]interface{}, selectFields []string) (string, []interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", nil, err
+	}
+	sql, args, _, err := engine.buildSelect(s, namedCondition, selectFields)
+	return sql, args, err
+}
+
+// QuerySQL 返回Query将要执行的语句及参数，不会访问数据库
+func (engine *Engine) QuerySQL(name, queryName string, params map[string]interface{}) (string, []interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", nil, err
+	}
+	q := s.GetQuery(queryName)
+	if q == nil {
+		return "", nil, ErrQueryNotFound
+	}
+
+	// 处理默认值、必填及参数类型
+	bound, err := q.BindParams(params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if q.SQL == "" {
+		return engine.dialect.BuildSelect(s.TableName, q.Fields, q.RenderWhere(bound))
+	}
+	// 命名参数转换为占位符，数组参数展开为in列表
+	sql, args, err := sqlx.Named(q.SQL, bound)
+	if err != nil {
+		return "", nil, err
+	}
+	sql, args, err = sqlx.In(sql, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	return engine.rebind(sql), args, nil
+}
+
+// UpdateSQL 返回Update将要执行的语句及参数，不会访问数据库
+func (engine *Engine) UpdateSQL(name string, data, namedCondition map[string]interface{}) (string, []interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", nil, err
+	}
+	data, namedCondition, _, err = updateRow(s, data, namedCondition)
+	if err != nil {
+		return "", nil, err
+	}
+	return engine.buildUpdate(s, data, namedCondition)
+}
+
+// updateRow 按照字段类型转换更新的数据，并处理版本号，返回是否校验了版本号
+func updateRow(s *schema.Schema, data, namedCondition map[string]interface{}) (map[string]interface{}, map[string]interface{}, bool, error) {
+	data, err := encodeRow(s, data)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	data, namedCondition, checked := withVersion(s, data, namedCondition)
+	return data, namedCondition, checked, nil
+}
+
+// buildUpdate 生成更新语句，不处理版本号
+func (engine *Engine) buildUpdate(s *schema.Schema, data, namedCondition map[string]interface{}) (string, []interface{}, error) {
+	if err := checkJSONPaths(s, namedCondition); err != nil {
+		return "", nil, err
+	}
+	where, err := engine.resolveSubQueries(namedCondition)
+	if err != nil {
+		return "", nil, err
+	}
+	// 条件中的字段在BuildUpdate中转换为蛇形命名
+	return engine.dialect.BuildUpdate(s.TableName, data, where)
+}
+
+// DeleteSQL 返回Delete将要执行的语句及参数，不会访问数据库
+func (engine *Engine) DeleteSQL(name string, namedCondition map[string]interface{}) (string, []interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", nil, err
+	}
+	// 条件为空时BuildDelete返回dialect.ErrEmptyWhere
+	if err = checkJSONPaths(s, namedCondition); err != nil {
+		return "", nil, err
+	}
+	where, err := engine.resolveSubQueries(namedCondition)
+	if err != nil {
+		return "", nil, err
+	}
+	// 条件中的字段在BuildDelete中转换为蛇形命名
+	return engine.dialect.BuildDelete(s.TableName, where)
+}
+
+// MigrateTableSQL 返回表不存在时MigrateTable将要执行的建表及建立索引的语句，不会访问数据库
+func (engine *Engine) MigrateTableSQL(name string) ([]string, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	sqls := append([]string{engine.dialect.CreateTableSQL(s)}, engine.dialect.IndexSQL(s)...)
+	return append(sqls, engine.dialect.FulltextIndexSQL(s)...), nil
+}
+
+// PivotTableSQL 返回模型的多对多关系中间表的建表及索引语句，不会访问数据库，关联模型需要已注册
+func (engine *Engine) PivotTableSQL(name string) ([]string, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return nil, err
+	}
+	pivots, err := engine.pivotSchemas(s)
+	if err != nil {
+		return nil, err
+	}
+	var sqls []string
+	for _, pivot := range pivots {
+		sqls = append(sqls, engine.pivotTableSQL(pivot)...)
+	}
+	return sqls, nil
+}
+
+func (engine *Engine) pivotTableSQL(pivot *schema.Schema) []string {
+	return append([]string{engine.dialect.CreateTableSQL(pivot)}, engine.dialect.IndexSQL(pivot)...)
+}
+
+// DropTableSQL 返回DropTable将要执行的语句，不会访问数据库
+func (engine *Engine) DropTableSQL(name string) (string, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", err
+	}
+	return engine.dialect.DropTableSQL(s), nil
+}
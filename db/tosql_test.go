@@ -0,0 +1,127 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	_ "github.com/yaochi-tech/lingquan-core-go/db/dialect/postgres"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"testing"
+)
+
+func TestEngine_ToSQL(t *testing.T) {
+	Convey("离线引擎生成sql", t, func() {
+		engine := newTestEngine(roleDef, memberDef, postDef, tagDef)
+
+		Convey("插入", func() {
+			sql, _, err := engine.InsertSQL("role", map[string]interface{}{"id": 1, "name": "admin"})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "INSERT INTO `role` (`id`, `name`) VALUES (1, 'admin')")
+		})
+
+		Convey("查询", func() {
+			sql, _, err := engine.FindSQL("member", map[string]interface{}{"role.name": "admin", "$limit": 10}, []string{"id", "name"})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT `member`.`id`, `member`.`name` FROM `member` "+
+				"LEFT JOIN `role` AS `role` ON (`role`.`id` = `member`.`role_id`) WHERE (`role`.`name` = 'admin') LIMIT 10")
+		})
+
+		Convey("更新", func() {
+			sql, _, err := engine.UpdateSQL("member", map[string]interface{}{"roleId": 2}, map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "UPDATE `member` SET `role_id`=2 WHERE (`id` = 1)")
+		})
+
+		Convey("删除", func() {
+			sql, _, err := engine.DeleteSQL("member", map[string]interface{}{"id in": []int{1, 2}})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "DELETE `member` FROM `member` WHERE (`id` IN (1, 2))")
+
+			_, _, err = engine.DeleteSQL("member", nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("迁移", func() {
+			sqls, err := engine.MigrateTableSQL("role")
+			So(err, ShouldBeNil)
+			So(sqls, ShouldResemble, []string{"CREATE TABLE IF NOT EXISTS `role` (`id` bigint NOT NULL,`name` varchar(255), PRIMARY KEY(`id`))"})
+
+			sql, err := engine.DropTableSQL("role")
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "DROP TABLE IF EXISTS `role`")
+		})
+
+		Convey("行锁", func() {
+			sql, _, err := engine.FindSQL("role", map[string]interface{}{"id": 1, "$lock": "update nowait"}, []string{"id"})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "SELECT `id` FROM `role` WHERE (`id` = 1) FOR UPDATE NOWAIT")
+
+			// 行锁只能在事务中使用
+			_, err = engine.Find("role", map[string]interface{}{"id": 1, "$lock": "update"}, nil)
+			So(err, ShouldEqual, ErrLockWithoutTx)
+			_, err = engine.FindByID("role", 1, nil)
+			So(err, ShouldEqual, ErrEngineOffline)
+		})
+
+		Convey("离线引擎不能执行语句", func() {
+			_, err := engine.Insert("role", map[string]interface{}{"id": 1, "name": "admin"})
+			So(err, ShouldEqual, ErrEngineOffline)
+			_, err = engine.Find("role", nil, nil)
+			So(err, ShouldEqual, ErrEngineOffline)
+			So(engine.MigrateTable("role"), ShouldEqual, ErrEngineOffline)
+			report, err := engine.Migrate(MigrateOptions{})
+			So(err, ShouldEqual, ErrEngineOffline)
+			So(report, ShouldBeNil)
+		})
+	})
+
+	Convey("postgres自定义查询的占位符", t, func() {
+		engine, err := NewOfflineEngine("postgres")
+		So(err, ShouldBeNil)
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		sql, args, err := engine.QuerySQL("user", "countByGender", map[string]interface{}{"genders": []string{"男", "女"}})
+		So(err, ShouldBeNil)
+		So(sql, ShouldEqual, "SELECT gender, COUNT(*) AS total FROM user WHERE gender IN ($1, $2) GROUP BY gender")
+		So(args, ShouldResemble, []interface{}{"男", "女"})
+
+		sql, _, err = engine.QuerySQL("user", "byUsername", map[string]interface{}{"username": "test"})
+		So(err, ShouldBeNil)
+		So(sql, ShouldEqual, `SELECT "id", "username", "nickname" FROM "user" WHERE ("username" = 'test')`)
+	})
+
+	Convey("插入或更新的默认冲突及更新字段", t, func() {
+		engine, err := NewOfflineEngine("postgres")
+		So(err, ShouldBeNil)
+		_, err = engine.Register(def)
+		So(err, ShouldBeNil)
+
+		// 有主键时按主键判断冲突，更新其他字段
+		sql, _, err := engine.UpsertSQL("user", []map[string]interface{}{{"id": 1, "username": "a", "nickname": "A"}}, nil, nil)
+		So(err, ShouldBeNil)
+		// 插入的数据补齐模型的默认值，默认值不作为更新字段
+		So(sql, ShouldEqual, `INSERT INTO "user" ("gender", "id", "nickname", "username") VALUES ('保密', 1, 'A', 'a') `+
+			`ON CONFLICT ("id") DO UPDATE SET "nickname" = excluded."nickname", "username" = excluded."username"`)
+
+		// 没有主键时使用唯一索引，空数组表示不更新
+		sql, _, err = engine.UpsertSQL("user", []map[string]interface{}{{"username": "a", "nickname": "A"}}, nil, []string{})
+		So(err, ShouldBeNil)
+		So(sql, ShouldEqual, `INSERT INTO "user" ("gender", "nickname", "username") VALUES ('保密', 'A', 'a') ON CONFLICT ("username") DO NOTHING`)
+
+		_, _, err = engine.UpsertSQL("user", []map[string]interface{}{{"nickname": "A"}}, nil, nil)
+		So(err, ShouldWrap, dialect.ErrInvalidUpsert)
+		_, _, err = engine.UpsertSQL("user", []map[string]interface{}{{"id": 1}}, []string{"age"}, nil)
+		So(err, ShouldWrap, schema.ErrInvalidField)
+
+		// 各行的字段不同时补齐缺少的字段
+		sql, _, err = engine.UpsertSQL("user", []map[string]interface{}{{"id": 1, "username": "a", "nickname": "A"}, {"id": 2, "username": "b"}}, nil, nil)
+		So(err, ShouldBeNil)
+		So(sql, ShouldEqual, `INSERT INTO "user" ("gender", "id", "nickname", "username") VALUES ('保密', 1, 'A', 'a'), ('保密', 2, NULL, 'b') `+
+			`ON CONFLICT ("id") DO UPDATE SET "nickname" = excluded."nickname", "username" = excluded."username"`)
+	})
+
+	Convey("不支持的方言", t, func() {
+		_, err := NewOfflineEngine("oracle")
+		So(err, ShouldNotBeNil)
+	})
+}
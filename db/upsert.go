@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+	"github.com/yaochi-tech/lingquan-core-go/util"
+)
+
+// Upsert 插入数据，与已有数据冲突时更新，返回影响的行数（各数据库对更新的行计数方式不同，如mysql更新的行计为2）
+// conflictFields为判断冲突的字段，为nil时使用主键，数据中没有主键时使用字段完整的第一个唯一索引
+// updateFields为冲突时更新的字段，为nil时更新数据中除冲突字段及主键以外的所有字段，为空数组时冲突的行不做更新
+// mysql按照表的主键及唯一索引判断冲突，conflictFields仅用于确定默认的更新字段
+// 各行的字段可以不同，缺少的字段使用字段的默认值补齐，冲突时同样更新为补齐的值；
+// 模型有版本号字段时，冲突并更新时版本号加1，数据中的版本号不会作为更新字段
+func (engine *Engine) Upsert(name string, data []map[string]interface{}, conflictFields, updateFields []string, tx ...*sqlx.Tx) (int64, error) {
+	return engine.UpsertContext(context.Background(), name, data, conflictFields, updateFields, tx...)
+}
+
+// UpsertContext 同Upsert，ctx用于取消及超时
+func (engine *Engine) UpsertContext(ctx context.Context, name string, data []map[string]interface{}, conflictFields, updateFields []string, tx ...*sqlx.Tx) (int64, error) {
+	sql, args, err := engine.UpsertSQL(name, data, conflictFields, updateFields)
+	if err != nil {
+		return 0, err
+	}
+	return engine.exec(ctx, sql, args, tx...)
+}
+
+// UpsertSQL 返回Upsert将要执行的语句及参数，不会访问数据库
+func (engine *Engine) UpsertSQL(name string, data []map[string]interface{}, conflictFields, updateFields []string) (string, []interface{}, error) {
+	s, err := engine.schemaOf(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("%w: no data", dialect.ErrInvalidUpsert)
+	}
+
+	// 数据中的列
+	columns := make(map[string]bool)
+	for _, row := range data {
+		for k := range row {
+			columns[util.ToSnake(k)] = true
+		}
+	}
+
+	var conflictColumns []string
+	if conflictFields == nil {
+		conflictColumns = defaultConflictColumns(s, columns)
+		if conflictColumns == nil {
+			return "", nil, fmt.Errorf("%w: no primary key or unique fields in data", dialect.ErrInvalidUpsert)
+		}
+	} else if conflictColumns, err = fieldColumns(s, conflictFields); err != nil {
+		return "", nil, err
+	}
+
+	var updateColumns []string
+	if updateFields == nil {
+		updateColumns = defaultUpdateColumns(s, columns, conflictColumns)
+	} else if updateColumns, err = fieldColumns(s, updateFields); err != nil {
+		return "", nil, err
+	}
+	var incrColumns []string
+	if field := s.VersionField(); field != nil {
+		// 版本号由数据库加1，不使用数据中的值
+		updateColumns = withoutColumn(updateColumns, field.Column)
+		incrColumns = append(incrColumns, field.Column)
+	}
+
+	// 冲突列及更新列按照传入的数据确定后再补齐默认值及生成主键，使没有主键的数据按唯一索引判断冲突
+	rows, err := insertRows(s, data)
+	if err != nil {
+		return "", nil, err
+	}
+	rows, _, err = fillIDs(s, rows)
+	if err != nil {
+		return "", nil, err
+	}
+	return engine.dialect.BuildUpsert(s.TableName, normalizeRows(s, rows), conflictColumns, updateColumns, incrColumns...)
+}
+
+// withoutColumn 返回去掉column后的列
+func withoutColumn(columns []string, column string) []string {
+	res := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if c != column {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// defaultConflictColumns 默认的冲突列：数据中有主键时为主键，否则为数据中字段完整的第一个唯一索引
+func defaultConflictColumns(s *schema.Schema, columns map[string]bool) []string {
+	if pk := s.PrimaryKey(); pk != nil && columns[pk.Column] {
+		return []string{pk.Column}
+	}
+	for _, index := range s.UniqueIndexes() {
+		complete := true
+		for _, column := range index.Columns {
+			complete = complete && columns[column]
+		}
+		if complete {
+			return index.Columns
+		}
+	}
+	return nil
+}
+
+// defaultUpdateColumns 默认的更新列：数据中除冲突列及主键以外的列，按列名排序
+func defaultUpdateColumns(s *schema.Schema, columns map[string]bool, conflictColumns []string) []string {
+	skip := make(map[string]bool)
+	for _, column := range conflictColumns {
+		skip[column] = true
+	}
+	if pk := s.PrimaryKey(); pk != nil {
+		skip[pk.Column] = true
+	}
+	updateColumns := []string{}
+	for column := range columns {
+		if !skip[column] {
+			updateColumns = append(updateColumns, column)
+		}
+	}
+	sort.Strings(updateColumns)
+	return updateColumns
+}
+
+// fieldColumns 将字段名转换为列名，字段不存在时返回错误
+func fieldColumns(s *schema.Schema, fields []string) ([]string, error) {
+	columns := make([]string, 0, len(fields))
+	for _, f := range fields {
+		field := s.GetFieldByColumn(util.ToSnake(f))
+		if field == nil {
+			return nil, fmt.Errorf("%w: field %s not found in model %s", schema.ErrInvalidField, f, s.Name)
+		}
+		columns = append(columns, field.Column)
+	}
+	return columns, nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/yaochi-tech/goqu"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"github.com/yaochi-tech/lingquan-core-go/db/schema"
+)
+
+// withVersion 模型有版本号字段时，更新的版本号改为加1，data中的版本号作为条件，返回是否校验了版本号
+// 不修改传入的data及条件
+func withVersion(s *schema.Schema, data, namedCondition map[string]interface{}) (map[string]interface{}, map[string]interface{}, bool) {
+	field := s.VersionField()
+	if field == nil {
+		return data, namedCondition, false
+	}
+	key, version := fieldValue(field, data)
+
+	versioned := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		if k != key {
+			versioned[k] = v
+		}
+	}
+	versioned[field.Column] = goqu.L("? + 1", goqu.I(field.Column))
+	if version == nil || len(namedCondition) == 0 {
+		// 没有条件时不使用版本号作为条件，由BuildUpdate拒绝没有条件的更新
+		return versioned, namedCondition, false
+	}
+
+	// 原条件放在$and中与版本号同时满足，$max_affected等选项保留在最外层，避免被忽略
+	condition := map[string]interface{}{field.Column: version}
+	where := make(map[string]interface{}, len(namedCondition))
+	for k, v := range namedCondition {
+		if isOption(k) {
+			condition[k] = v
+		} else {
+			where[k] = v
+		}
+	}
+	if len(where) > 0 {
+		condition[dialect.OP_AND] = where
+	}
+	return versioned, condition, true
+}
+
+// isOption 判断条件中的key是否为选项，如：$max_affected、$all，$or/$and为条件
+func isOption(k string) bool {
+	return strings.HasPrefix(k, "$") && k != dialect.OP_OR && k != dialect.OP_AND
+}
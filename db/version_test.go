@@ -0,0 +1,72 @@
+package db
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/yaochi-tech/lingquan-core-go/db/dialect"
+	"testing"
+)
+
+const docDef = `{
+  "code": "doc",
+  "name": "文档",
+  "fields": [
+    {"label": "主键", "name": "id", "type": "ID"},
+    {"label": "标题", "name": "title", "type": "string"},
+    {"label": "版本", "name": "lockVersion", "type": "int", "version": true}
+  ]
+}`
+
+func TestEngine_VersionSQL(t *testing.T) {
+	Convey("乐观锁", t, func() {
+		engine := newTestEngine(docDef)
+
+		Convey("版本号作为条件并加1", func() {
+			data := map[string]interface{}{"title": "b", "lockVersion": 3}
+			sql, _, err := engine.UpdateSQL("doc", data, map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "UPDATE `doc` SET `lock_version`=`lock_version` + 1,`title`='b' WHERE ((`id` = 1) AND (`lock_version` = 3))")
+			So(data["lockVersion"], ShouldEqual, 3)
+		})
+
+		Convey("校验版本号时选项保留在最外层", func() {
+			data := map[string]interface{}{"title": "b", "lockVersion": 3}
+			cond := map[string]interface{}{"id": 1, "$max_affected": 1}
+			_, versioned, checked := withVersion(engine.GetSchema("doc"), data, cond)
+			So(checked, ShouldBeTrue)
+			So(versioned, ShouldResemble, map[string]interface{}{
+				"lock_version":  3,
+				"$max_affected": 1,
+				"$and":          map[string]interface{}{"id": 1},
+			})
+
+			// $max_affected不是整数时返回错误，说明Update读取到了该选项
+			_, err := engine.Update("doc", data, map[string]interface{}{"id": 1, "$max_affected": "1"})
+			So(err, ShouldWrap, dialect.ErrInvalidUpdate)
+		})
+
+		Convey("没有传入版本号时只加1", func() {
+			sql, _, err := engine.UpdateSQL("doc", map[string]interface{}{"title": "b"}, map[string]interface{}{"id": 1})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "UPDATE `doc` SET `lock_version`=`lock_version` + 1,`title`='b' WHERE (`id` = 1)")
+		})
+
+		Convey("插入或更新时版本号加1", func() {
+			data := []map[string]interface{}{{"id": 1, "title": "a", "lockVersion": 5}, {"id": 2}}
+			sql, _, err := engine.UpsertSQL("doc", data, nil, nil)
+			So(err, ShouldBeNil)
+			So(sql, ShouldEqual, "INSERT INTO `doc` (`id`, `lock_version`, `title`) VALUES (1, 5, 'a'), (2, 1, NULL) "+
+				"ON DUPLICATE KEY UPDATE `title` = VALUES(`title`), `lock_version` = `lock_version` + 1")
+
+			// 冲突时不更新则版本号不变
+			sql, _, err = engine.UpsertSQL("doc", data, nil, []string{})
+			So(err, ShouldBeNil)
+			So(sql, ShouldEndWith, "ON DUPLICATE KEY UPDATE `id` = `id`")
+		})
+
+		Convey("版本号默认为1", func() {
+			sqls, err := engine.MigrateTableSQL("doc")
+			So(err, ShouldBeNil)
+			So(sqls[0], ShouldContainSubstring, "`lock_version` int NOT NULL DEFAULT 1")
+		})
+	})
+}
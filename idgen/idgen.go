@@ -0,0 +1,89 @@
+// Package idgen 主键生成器，模型中ID类型的字段可以通过generator指定生成器，插入数据时自动生成缺少的主键
+package idgen
+
+import (
+	"errors"
+	"sync"
+)
+
+// 内置的生成器名称
+const (
+	SNOWFLAKE = "snowflake"
+	ULID      = "ulid"
+	UUID      = "uuid"
+)
+
+// 生成的id类型，决定主键的字段类型
+const (
+	TYPE_INT64  = "int64"
+	TYPE_STRING = "string"
+)
+
+var (
+	ErrGeneratorNotFound error = errors.New("id generator not found")
+)
+
+// Generator 主键生成器
+type Generator interface {
+	// Generate 生成一个新的id，应是并发安全的
+	Generate() (interface{}, error)
+	// Type 生成的id类型，TYPE_INT64或TYPE_STRING
+	Type() string
+}
+
+// GeneratorFunc 使用函数作为生成器，typ为生成的id类型
+func GeneratorFunc(typ string, fn func() (interface{}, error)) Generator {
+	return &funcGenerator{typ: typ, fn: fn}
+}
+
+type funcGenerator struct {
+	typ string
+	fn  func() (interface{}, error)
+}
+
+func (g *funcGenerator) Generate() (interface{}, error) {
+	return g.fn()
+}
+
+func (g *funcGenerator) Type() string {
+	return g.typ
+}
+
+var (
+	generators = map[string]Generator{
+		SNOWFLAKE: defaultSnowflake,
+		ULID:      GeneratorFunc(TYPE_STRING, func() (interface{}, error) { return NewULID() }),
+		UUID:      GeneratorFunc(TYPE_STRING, func() (interface{}, error) { return NewUUIDv7() }),
+	}
+	lock sync.RWMutex
+)
+
+// Register 注册生成器，名称相同时替换已有的生成器，如：设置snowflake的机器id
+//
+//	snowflake, err := idgen.NewSnowflake(1)
+//	if err != nil {
+//		return err
+//	}
+//	idgen.Register(idgen.SNOWFLAKE, snowflake)
+func Register(name string, generator Generator) {
+	lock.Lock()
+	defer lock.Unlock()
+	generators[name] = generator
+}
+
+// Get 获取生成器
+func Get(name string) (Generator, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	generator, ok := generators[name]
+	return generator, ok
+}
+
+// Generate 使用指定的生成器生成id
+func Generate(name string) (interface{}, error) {
+	generator, ok := Get(name)
+	if !ok {
+		return nil, ErrGeneratorNotFound
+	}
+	return generator.Generate()
+}
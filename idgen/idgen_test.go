@@ -0,0 +1,113 @@
+package idgen
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnowflake(t *testing.T) {
+	if _, err := NewSnowflake(maxWorkerID + 1); !errors.Is(err, ErrInvalidWorkerID) {
+		t.Fatalf("NewSnowflake() error = %v, want ErrInvalidWorkerID", err)
+	}
+
+	sf, err := NewSnowflake(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 并发生成的id不重复，且包含机器id
+	const n = 10000
+	ids := make(chan int64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n/4; j++ {
+				id, err := sf.NextID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[int64]bool, n)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicated id %d", id)
+		}
+		seen[id] = true
+		if worker := id >> sequenceBits & maxWorkerID; worker != 3 {
+			t.Fatalf("worker id = %d, want 3", worker)
+		}
+	}
+
+	// 时钟回拨
+	sf.now = func() int64 { return sf.lastTime - 1 }
+	if _, err := sf.NextID(); !errors.Is(err, ErrClockBackwards) {
+		t.Errorf("NextID() error = %v, want ErrClockBackwards", err)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := NewULID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pattern.MatchString(id) {
+			t.Fatalf("NewULID() = %q, invalid format", id)
+		}
+		ids = append(ids, id)
+		time.Sleep(2 * time.Millisecond)
+	}
+	// 时间戳在前，不同毫秒生成的id按时间排序
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ULIDs are not ordered by time: %v", ids)
+	}
+}
+
+func TestNewUUIDv7(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for i := 0; i < 100; i++ {
+		id, err := NewUUIDv7()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pattern.MatchString(id) {
+			t.Fatalf("NewUUIDv7() = %q, invalid format", id)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if _, err := Generate("seq"); !errors.Is(err, ErrGeneratorNotFound) {
+		t.Fatalf("Generate() error = %v, want ErrGeneratorNotFound", err)
+	}
+
+	var next int64
+	Register("seq", GeneratorFunc(TYPE_INT64, func() (interface{}, error) {
+		next++
+		return next, nil
+	}))
+	for _, want := range []int64{1, 2} {
+		id, err := Generate("seq")
+		if err != nil || id != want {
+			t.Errorf("Generate() = %v, %v, want %d", id, err, want)
+		}
+	}
+	for _, name := range []string{SNOWFLAKE, ULID, UUID} {
+		if _, err := Generate(name); err != nil {
+			t.Errorf("Generate(%s) error = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	workerBits   = 10
+	sequenceBits = 12
+	maxWorkerID  = 1<<workerBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+var (
+	// snowflake的起始时间，2020-01-01 00:00:00 UTC
+	snowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	ErrInvalidWorkerID error = errors.New("invalid worker id")
+	ErrClockBackwards  error = errors.New("clock moved backwards")
+)
+
+var defaultSnowflake, _ = NewSnowflake(0)
+
+// Snowflake 雪花算法生成器，id由41位毫秒时间戳、10位机器id及12位序列号组成，同一个数据库的各个进程应使用不同的机器id
+type Snowflake struct {
+	workerID int64
+	lastTime int64
+	sequence int64
+	lock     sync.Mutex
+	now      func() int64
+}
+
+// NewSnowflake 创建雪花算法生成器，workerID为机器id，取值范围0~1023
+func NewSnowflake(workerID int64) (*Snowflake, error) {
+	if workerID < 0 || workerID > maxWorkerID {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWorkerID, workerID)
+	}
+	return &Snowflake{workerID: workerID, now: func() int64 { return time.Now().UnixMilli() }}, nil
+}
+
+// NextID 生成一个新的id，同一毫秒内序列号用完时等待下一毫秒，时钟回拨时返回ErrClockBackwards
+func (sf *Snowflake) NextID() (int64, error) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	now := sf.now()
+	if now < sf.lastTime {
+		return 0, fmt.Errorf("%w: %dms", ErrClockBackwards, sf.lastTime-now)
+	}
+	if now == sf.lastTime {
+		sf.sequence = (sf.sequence + 1) & maxSequence
+		if sf.sequence == 0 {
+			for now <= sf.lastTime {
+				now = sf.now()
+			}
+		}
+	} else {
+		sf.sequence = 0
+	}
+	sf.lastTime = now
+	return (now-snowflakeEpoch)<<(workerBits+sequenceBits) | sf.workerID<<sequenceBits | sf.sequence, nil
+}
+
+func (sf *Snowflake) Generate() (interface{}, error) {
+	return sf.NextID()
+}
+
+func (sf *Snowflake) Type() string {
+	return TYPE_INT64
+}
@@ -0,0 +1,66 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Crockford base32编码的字符表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID 生成ULID，由48位毫秒时间戳及80位随机数组成，编码为26个字符，按时间排序
+func NewULID() (string, error) {
+	var b [16]byte
+	putMillis(b[:6], time.Now().UnixMilli())
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	// 128位按5位一组编码，首个字符只有3位
+	out := make([]byte, 26)
+	// 前面补2位，使总位数为130，可以被5整除
+	var acc uint64
+	bits, idx := uint(2), 0
+	for _, v := range b {
+		acc = acc<<8 | uint64(v)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[idx] = crockford[(acc>>bits)&0x1f]
+			idx++
+		}
+	}
+	return string(out), nil
+}
+
+// NewUUIDv7 生成UUID v7，由48位毫秒时间戳及随机数组成，按时间排序
+func NewUUIDv7() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putMillis(b[:6], time.Now().UnixMilli())
+	b[6] = b[6]&0x0f | 0x70 // 版本7
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122变体
+
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:]), nil
+}
+
+// putMillis 将毫秒时间戳按大端序写入6个字节
+func putMillis(b []byte, ms int64) {
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}